@@ -2,11 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
 	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
@@ -15,10 +22,15 @@ import (
 
 func main() {
 	dir := flag.String("directory", "", "Path to the directory containing files")
+	tlsCert := flag.String("tls-cert", "", "Path to a PEM certificate, serves HTTPS together with --tls-key")
+	tlsKey := flag.String("tls-key", "", "Path to the PEM private key for --tls-cert")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "How long to wait for in-flight requests on SIGINT/SIGTERM, 0 disables graceful shutdown")
 	flag.Parse()
 	fmt.Println("Logs from your program will appear here!")
 
-	httpServer := server.CreateServer()
+	httpServer := server.CreateServerWithConfig(server.ServerConfig{
+		ReadHeaderTimeout: 10 * time.Second,
+	})
 
 	httpServer.Get("/", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
 		res.Status(200).Send([]byte("cool"))
@@ -27,11 +39,13 @@ func main() {
 	httpServer.Get("/ping", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
 		buff := bytes.Buffer{}
 
-		for k, v := range req.Header {
-			buff.WriteString(k)
-			buff.WriteString(": ")
-			buff.WriteString(v)
-			buff.WriteString("\r\n")
+		for _, k := range req.Header.Keys() {
+			for _, v := range req.Header.Values(k) {
+				buff.WriteString(k)
+				buff.WriteString(": ")
+				buff.WriteString(v)
+				buff.WriteString("\r\n")
+			}
 		}
 
 		res.Send(buff.Bytes())
@@ -64,7 +78,12 @@ func main() {
 			return
 		}
 
-		fullPath := filepath.Join(*dir, req.Params["filename"])
+		fullPath, ok := filePath(*dir, req.Params["filename"])
+		if !ok {
+			res.Status(int(constants.StatusBadRequest)).Send([]byte("Invalid file name"))
+			return
+		}
+
 		fileData, err := os.ReadFile(fullPath)
 
 		if err != nil {
@@ -93,7 +112,12 @@ func main() {
 			return
 		}
 
-		fullPath := filepath.Join(*dir, req.Params["filename"])
+		fullPath, ok := filePath(*dir, req.Params["filename"])
+		if !ok {
+			res.Status(int(constants.StatusBadRequest)).Send([]byte("Invalid file name"))
+			return
+		}
+
 		file, err := os.Create(fullPath)
 
 		if err != nil {
@@ -102,20 +126,62 @@ func main() {
 			return
 		}
 
-		log.Printf("Raw req.Body: %q", req.Body)
-		n, err := file.Write(req.Body)
+		n, err := io.Copy(file, req.BodyReader())
+		file.Close()
 		if err != nil {
 			log.Printf("Error writing to file: %v", err)
+			os.Remove(fullPath)
 			res.Status(500).Send([]byte("Internal Server Error"))
 			return
 		}
-		file.Close()
-		log.Printf("Wrote %s of size %d bytes to file", req.Body, n)
+		log.Printf("Wrote %s of size %d bytes to file", req.Params["filename"], n)
 
 		res.Status(201).End()
 	})
 
-	httpServer.Listen("0.0.0.0:4221", func() {
+	shutdownDone := make(chan struct{})
+	if *shutdownTimeout > 0 {
+		go func() {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+			<-signals
+
+			ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+			defer cancel()
+
+			if err := httpServer.Shutdown(ctx); err != nil {
+				log.Printf("Graceful shutdown failed: %v", err)
+			}
+			close(shutdownDone)
+		}()
+	}
+
+	listenCallback := func() {
 		fmt.Println("listen callback")
-	})
+	}
+
+	var err error
+	if *tlsCert != "" || *tlsKey != "" {
+		err = httpServer.ListenTLS("0.0.0.0:4221", *tlsCert, *tlsKey, listenCallback)
+	} else {
+		err = httpServer.Listen("0.0.0.0:4221", listenCallback)
+	}
+	if !errors.Is(err, server.ErrServerClosed) {
+		os.Exit(1)
+	}
+	<-shutdownDone
+}
+
+// filePath joins a file name taken from the URL onto dir, params are decoded
+// so names holding a separator or ".." are refused rather than escaping dir.
+func filePath(dir string, name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return "", false
+	}
+
+	fullPath := filepath.Join(dir, name)
+	if rel, err := filepath.Rel(dir, fullPath); err != nil || rel != filepath.Base(fullPath) {
+		return "", false
+	}
+	return fullPath, true
 }
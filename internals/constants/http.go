@@ -8,6 +8,10 @@ const (
 	PATCH   = "PATCH"
 	OPTIONS = "OPTIONS"
 	HEAD    = "HEAD"
+
+	// ANY is not a method token so it can never clash with a real method,
+	// routes registered under it match whatever method has no own handler
+	ANY = "*"
 )
 
 var AllHTTPMethods = []string{
@@ -23,6 +27,8 @@ var AllHTTPMethods = []string{
 type StatusCode int
 
 const (
+	StatusContinue StatusCode = 100
+
 	StatusOK        StatusCode = 200
 	StatusCreated   StatusCode = 201
 	StatusAccepted  StatusCode = 202
@@ -32,21 +38,29 @@ const (
 	StatusFound            StatusCode = 302
 	StatusNotModified      StatusCode = 304
 
-	StatusBadRequest          StatusCode = 400
-	StatusUnauthorized        StatusCode = 401
-	StatusForbidden           StatusCode = 403
-	StatusNotFound            StatusCode = 404
-	StatusMethodNotAllowed    StatusCode = 405
-	StatusConflict            StatusCode = 409
-	StatusUnprocessableEntity StatusCode = 422
-
-	StatusInternalServerError StatusCode = 500
-	StatusNotImplemented      StatusCode = 501
-	StatusBadGateway          StatusCode = 502
-	StatusServiceUnavailable  StatusCode = 503
+	StatusBadRequest                  StatusCode = 400
+	StatusUnauthorized                StatusCode = 401
+	StatusForbidden                   StatusCode = 403
+	StatusNotFound                    StatusCode = 404
+	StatusMethodNotAllowed            StatusCode = 405
+	StatusRequestTimeout              StatusCode = 408
+	StatusConflict                    StatusCode = 409
+	StatusRequestEntityTooLarge       StatusCode = 413
+	StatusRequestURITooLong           StatusCode = 414
+	StatusExpectationFailed           StatusCode = 417
+	StatusUnprocessableEntity         StatusCode = 422
+	StatusRequestHeaderFieldsTooLarge StatusCode = 431
+
+	StatusInternalServerError     StatusCode = 500
+	StatusNotImplemented          StatusCode = 501
+	StatusBadGateway              StatusCode = 502
+	StatusServiceUnavailable      StatusCode = 503
+	StatusHTTPVersionNotSupported StatusCode = 505
 )
 
 var StatusTexts = map[StatusCode]string{
+	StatusContinue: "Continue",
+
 	StatusOK:        "OK",
 	StatusCreated:   "Created",
 	StatusAccepted:  "Accepted",
@@ -56,18 +70,24 @@ var StatusTexts = map[StatusCode]string{
 	StatusFound:            "Found",
 	StatusNotModified:      "Not Modified",
 
-	StatusBadRequest:          "Bad Request",
-	StatusUnauthorized:        "Unauthorized",
-	StatusForbidden:           "Forbidden",
-	StatusNotFound:            "Not Found",
-	StatusMethodNotAllowed:    "Method Not Allowed",
-	StatusConflict:            "Conflict",
-	StatusUnprocessableEntity: "Unprocessable Entity",
-
-	StatusInternalServerError: "Internal Server Error",
-	StatusNotImplemented:      "Not Implemented",
-	StatusBadGateway:          "Bad Gateway",
-	StatusServiceUnavailable:  "Service Unavailable",
+	StatusBadRequest:                  "Bad Request",
+	StatusUnauthorized:                "Unauthorized",
+	StatusForbidden:                   "Forbidden",
+	StatusNotFound:                    "Not Found",
+	StatusMethodNotAllowed:            "Method Not Allowed",
+	StatusRequestTimeout:              "Request Timeout",
+	StatusConflict:                    "Conflict",
+	StatusRequestEntityTooLarge:       "Request Entity Too Large",
+	StatusRequestURITooLong:           "Request-URI Too Long",
+	StatusExpectationFailed:           "Expectation Failed",
+	StatusUnprocessableEntity:         "Unprocessable Entity",
+	StatusRequestHeaderFieldsTooLarge: "Request Header Fields Too Large",
+
+	StatusInternalServerError:     "Internal Server Error",
+	StatusNotImplemented:          "Not Implemented",
+	StatusBadGateway:              "Bad Gateway",
+	StatusServiceUnavailable:      "Service Unavailable",
+	StatusHTTPVersionNotSupported: "HTTP Version Not Supported",
 }
 
 type ContentType string
@@ -1,10 +1,84 @@
 package httpx
 
-import "github.com/codecrafters-io/http-server-starter-go/internals/util"
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/util"
+)
+
+type Header = util.Header
 
 type HttpRequest struct {
 	util.ParsedRequestInfo
 	URL       string
 	Params    map[string]string
 	PathParts []string
+	// TLS holds the negotiated connection state, SNI, protocol and peer
+	// certificates, nil for plain connections.
+	TLS   *tls.ConnectionState
+	ctx   context.Context
+	query url.Values
+}
+
+// Query parses the raw query string on first use, malformed pairs are
+// skipped.
+func (req *HttpRequest) Query() url.Values {
+	if req.query == nil {
+		req.query, _ = url.ParseQuery(req.RawQuery)
+	}
+	return req.query
+}
+
+// Context is cancelled when the client disconnects, the connection closes,
+// the handler times out or the server gives up on a graceful shutdown.
+func (req *HttpRequest) Context() context.Context {
+	if req.ctx == nil {
+		return context.Background()
+	}
+	return req.ctx
+}
+
+// WithContext replaces the request context in place, since middlewares hand
+// the same request to next() the new context reaches the handler.
+func (req *HttpRequest) WithContext(ctx context.Context) *HttpRequest {
+	if ctx == nil {
+		panic("nil context")
+	}
+	req.ctx = ctx
+	return req
+}
+
+// ParamInt parses a route param as an integer, pair it with an {name:int}
+// constraint so only numbers reach the handler.
+func (req *HttpRequest) ParamInt(name string) (int, error) {
+	return strconv.Atoi(req.Params[name])
+}
+
+// BodyReader streams the request body from the connection, once it has been
+// consumed Body or ReadBody hold the only copy.
+func (req *HttpRequest) BodyReader() io.ReadCloser {
+	if req.BodyStream == nil {
+		return io.NopCloser(strings.NewReader(""))
+	}
+	return req.BodyStream
+}
+
+// ReadBody buffers the whole request body into req.Body.
+func (req *HttpRequest) ReadBody() ([]byte, error) {
+	if req.Body != nil || req.BodyStream == nil {
+		return req.Body, nil
+	}
+
+	body, err := io.ReadAll(req.BodyStream)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = body
+	return req.Body, nil
 }
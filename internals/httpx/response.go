@@ -1,31 +1,57 @@
 package httpx
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+	"github.com/codecrafters-io/http-server-starter-go/internals/util"
 )
 
 type HttpResponse struct {
-	conn           net.Conn
+	conn           *bufio.Writer
 	sent           bool
 	headersWritten bool
-	headers        map[string]string
+	headers        Header
 	status         int
 	statusText     string
 	bodyBuffer     *bytes.Buffer
 	protocol       string
+	// streaming is set once the head of a chunked response is on the wire
+	streaming bool
+	// suppressBody answers HEAD requests with the headers a GET would get
+	suppressBody bool
+	// onWriteHead runs once right before the status line and headers go out
+	onWriteHead func()
+}
+
+// isChunked reports whether the body goes out with chunked encoding, HTTP/1.0
+// clients don't understand it so their responses are buffered instead.
+func (res *HttpResponse) isChunked() bool {
+	if res.headers.Get("Transfer-Encoding") != "chunked" {
+		return false
+	}
+
+	if res.protocol == "HTTP/1.0" {
+		res.headers.Del("Transfer-Encoding")
+		return false
+	}
+	return true
 }
 
 func (res *HttpResponse) writeChunk(data []byte) {
+	// an empty chunk would end the body
+	if len(data) == 0 || res.suppressBody {
+		return
+	}
+
 	chunkSize := fmt.Sprintf("%x\r\n", len(data))
 	res.conn.Write([]byte(chunkSize))
 
@@ -34,12 +60,17 @@ func (res *HttpResponse) writeChunk(data []byte) {
 }
 
 func (res *HttpResponse) fillDefaults() {
+	if res.onWriteHead != nil {
+		res.onWriteHead()
+		res.onWriteHead = nil
+	}
+
 	if res.protocol == "" {
 		res.protocol = "HTTP/1.1"
 	}
 
 	if res.headers == nil {
-		res.headers = make(map[string]string)
+		res.headers = make(Header)
 	}
 
 	if res.status == 0 {
@@ -50,18 +81,20 @@ func (res *HttpResponse) fillDefaults() {
 		res.statusText = constants.StatusTexts[constants.StatusCode(res.status)]
 	}
 
-	if _, exists := res.headers["Content-Length"]; !exists &&
-		res.bodyBuffer.Len() > 0 &&
-		res.headers["Transfer-Encoding"] != "chunked" {
-		res.headers["Content-Length"] = strconv.Itoa(res.bodyBuffer.Len())
+	// always declare a length so keep-alive clients know where the body ends
+	if !res.headers.Has("Content-Length") &&
+		res.headers.Get("Transfer-Encoding") != "chunked" &&
+		res.status != int(constants.StatusNoContent) &&
+		res.status != int(constants.StatusNotModified) {
+		res.headers.Set("Content-Length", strconv.Itoa(res.bodyBuffer.Len()))
 	}
 
-	if _, exists := res.headers["Date"]; !exists {
-		res.headers["Date"] = time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")
+	if !res.headers.Has("Date") {
+		res.headers.Set("Date", time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
 	}
 
-	if _, exists := res.headers["Server"]; !exists {
-		res.headers["Server"] = "CustomServer/1.0"
+	if !res.headers.Has("Server") {
+		res.headers.Set("Server", "CustomServer/1.0")
 	}
 }
 
@@ -73,6 +106,16 @@ func (res *HttpResponse) buildHTTPResponse() []byte {
 	estimatedSize := 512 + res.bodyBuffer.Len()
 	buf.Grow(estimatedSize)
 
+	res.writeHead(&buf)
+
+	if res.bodyBuffer.Len() > 0 && !res.suppressBody {
+		buf.Write(res.bodyBuffer.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+func (res *HttpResponse) writeHead(buf *bytes.Buffer) {
 	buf.WriteString(res.protocol)
 	buf.WriteByte(' ')
 	buf.WriteString(strconv.Itoa(int(res.status)))
@@ -80,20 +123,32 @@ func (res *HttpResponse) buildHTTPResponse() []byte {
 	buf.WriteString(res.statusText)
 	buf.WriteString("\r\n")
 
-	for name, value := range res.headers {
-		buf.WriteString(name)
-		buf.WriteString(": ")
-		buf.WriteString(value)
-		buf.WriteString("\r\n")
+	for _, name := range res.headers.Keys() {
+		for _, value := range res.headers[name] {
+			buf.WriteString(name)
+			buf.WriteString(": ")
+			buf.WriteString(value)
+			buf.WriteString("\r\n")
+		}
 	}
 
 	buf.WriteString("\r\n")
+}
 
-	if res.bodyBuffer.Len() > 0 {
-		buf.Write(res.bodyBuffer.Bytes())
-	}
+// startStreaming puts the head of a chunked response on the wire so chunks
+// can follow it.
+func (res *HttpResponse) startStreaming() error {
+	var buf bytes.Buffer
 
-	return buf.Bytes()
+	res.fillDefaults()
+	res.writeHead(&buf)
+
+	if _, err := res.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	res.streaming = true
+	res.headersWritten = true
+	return nil
 }
 
 func getStatusText(code constants.StatusCode) string {
@@ -103,11 +158,11 @@ func getStatusText(code constants.StatusCode) string {
 	return "Unknown"
 }
 
-func NewResponse(conn net.Conn) (res *HttpResponse) {
+func NewResponse(conn *bufio.Writer) (res *HttpResponse) {
 	return &HttpResponse{
 		conn:       conn,
 		status:     200,
-		headers:    make(map[string]string),
+		headers:    make(Header),
 		statusText: "",
 		bodyBuffer: &bytes.Buffer{},
 	}
@@ -116,15 +171,29 @@ func NewResponse(conn net.Conn) (res *HttpResponse) {
 
 func (res *HttpResponse) SetHeader(key, value string) {
 	if res.headersWritten {
-		fmt.Println("header already written cannot write key: %s and value: %s", key, value)
+		fmt.Printf("header already written cannot write key: %s and value: %s\n", key, value)
 		return
 	}
-	res.headers[key] = value
+	res.headers.Set(key, value)
 }
 
-func (res *HttpResponse) WriteHeader(status int, header map[string]string) {
+func (res *HttpResponse) AddHeader(key, value string) {
 	if res.headersWritten {
-		fmt.Println("header already written cannot write status: %s", status)
+		fmt.Printf("header already written cannot write key: %s and value: %s\n", key, value)
+		return
+	}
+	res.headers.Add(key, value)
+}
+
+// Header gives direct access to the response headers for multi-valued
+// fields, changes after the headers are written have no effect.
+func (res *HttpResponse) Header() Header {
+	return res.headers
+}
+
+func (res *HttpResponse) WriteHeader(status int, header Header) {
+	if res.headersWritten {
+		fmt.Printf("header already written cannot write status: %d\n", status)
 		return
 	}
 	if res.protocol == "" {
@@ -132,7 +201,7 @@ func (res *HttpResponse) WriteHeader(status int, header map[string]string) {
 	}
 	if header != nil {
 		for k, v := range header {
-			res.headers[k] = v
+			res.headers[util.CanonicalHeaderKey(k)] = append([]string(nil), v...)
 		}
 	}
 	res.status = status
@@ -145,11 +214,19 @@ func (res *HttpResponse) Write(data []byte) {
 		return
 	}
 
-	if res.headers["Transfer-Encoding"] == "chunked" {
-		res.writeChunk(data)
-	} else {
+	if !res.isChunked() {
 		res.bodyBuffer.Write(data)
+		return
+	}
+
+	if !res.streaming {
+		if err := res.startStreaming(); err != nil {
+			return
+		}
 	}
+
+	res.writeChunk(data)
+	res.conn.Flush()
 }
 
 func (res *HttpResponse) Send(body []byte) error {
@@ -157,8 +234,8 @@ func (res *HttpResponse) Send(body []byte) error {
 		return errors.New("already sent a response")
 	}
 
-	if _, exists := res.headers["Content-Type"]; !exists && len(body) > 0 {
-		res.headers["Content-Type"] = "text/plain"
+	if !res.headers.Has("Content-Type") && len(body) > 0 {
+		res.headers.Set("Content-Type", "text/plain")
 	}
 
 	res.bodyBuffer.Write(body)
@@ -171,12 +248,12 @@ func (res *HttpResponse) SendFile(filename string, body []byte) error {
 		return errors.New("response already sent")
 	}
 
-	if _, exists := res.headers["Content-Type"]; !exists {
+	if !res.headers.Has("Content-Type") {
 		ext := strings.ToLower(filepath.Ext(filename))
 		if contentType, ok := constants.ExtToMime[ext]; ok {
-			res.headers["Content-Type"] = contentType
+			res.headers.Set("Content-Type", contentType)
 		} else {
-			res.headers["Content-Type"] = "application/octet-stream"
+			res.headers.Set("Content-Type", "application/octet-stream")
 		}
 	}
 
@@ -186,7 +263,7 @@ func (res *HttpResponse) SendFile(filename string, body []byte) error {
 }
 
 func (res *HttpResponse) SendHTML(body []byte) error {
-	res.headers["Content-Type"] = "text/html; charset=utf-8"
+	res.headers.Set("Content-Type", "text/html; charset=utf-8")
 	return res.Send(body)
 }
 
@@ -197,7 +274,7 @@ func (res *HttpResponse) SendJSON(data map[string]any) error {
 		return errors.New("Could not encode json")
 	}
 
-	res.headers["Content-Type"] = "application/json"
+	res.headers.Set("Content-Type", "application/json")
 	res.bodyBuffer.Write(s)
 	res.Flush()
 
@@ -225,16 +302,60 @@ func (res *HttpResponse) End() error {
 }
 
 func (res *HttpResponse) Flush() error {
+	if res.sent {
+		return errors.New("response already sent")
+	}
+
+	if res.isChunked() {
+		if !res.streaming {
+			if err := res.startStreaming(); err != nil {
+				return err
+			}
+		}
+
+		res.writeChunk(res.bodyBuffer.Bytes())
+		res.bodyBuffer.Reset()
+		if !res.suppressBody {
+			if _, err := res.conn.WriteString("0\r\n\r\n"); err != nil {
+				return err
+			}
+		}
+		res.sent = true
+		return nil
+	}
+
 	response := res.buildHTTPResponse()
 	_, err := res.conn.Write(response)
 	if err != nil {
 		return err
 	}
 	res.sent = true
-	err = res.conn.Close()
-	if err != nil {
-		return err
-	}
 
 	return nil
 }
+
+// OnWriteHead registers fn to run right before the head of the response is
+// written, it can still change the headers through Header.
+func (res *HttpResponse) OnWriteHead(fn func()) {
+	res.onWriteHead = fn
+}
+
+// SuppressBody keeps the headers, Content-Length included, but drops the
+// body bytes, the server uses it to answer HEAD requests.
+func (res *HttpResponse) SuppressBody() {
+	res.suppressBody = true
+}
+
+// SetProtocol sets the version on the status line, the server matches it to
+// the request so HTTP/1.0 clients get HTTP/1.0 answers.
+func (res *HttpResponse) SetProtocol(protocol string) {
+	res.protocol = protocol
+}
+
+func (res *HttpResponse) Sent() bool {
+	return res.sent
+}
+
+func (res *HttpResponse) GetHeader(key string) string {
+	return res.headers.Get(key)
+}
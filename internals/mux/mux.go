@@ -1,22 +1,48 @@
 package mux
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
 	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
 )
 
 type HttpMuxTrieNode struct {
-	segment     string
+	segment string
+	// pattern is the route that created the node and patterns the route
+	// behind each handler, both name the other side of a conflict
+	pattern     string
+	patterns    map[string]string
 	handler     map[string]func(request *httpx.HttpRequest, response *httpx.HttpResponse)
 	middlewares []func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())
 
 	staticChildren map[string]*HttpMuxTrieNode
 
-	paramChild *HttpMuxTrieNode
-	paramName  string
+	// paramChildren hold the constrained params in registration order and
+	// the unconstrained one last, the first that accepts a segment wins
+	paramChildren []*HttpMuxTrieNode
+	paramName     string
+	constraint    string
+	matcher       *regexp.Regexp
+
+	// catchAllChild swallows every remaining segment, it is tried after the
+	// static and param children. An optional catch-all also matches when
+	// nothing remains, every route on it has to agree on that
+	catchAllChild *HttpMuxTrieNode
+	optional      bool
+
+	// maxBodyBytes overrides the server body limit for this node and the
+	// nodes below it, zero inherits and a negative value lifts the limit
+	maxBodyBytes int64
+	// mounted is the mux serving what this catch-all captures, its body
+	// limits apply on top of the ones leading here
+	mounted *HttpMux
 }
 
 type HttpMux struct {
@@ -28,8 +54,16 @@ type RouteMatch struct {
 	Middlewares []func(*httpx.HttpRequest, *httpx.HttpResponse, func())
 	Params      map[string]string
 	Found       bool
+	// Allowed lists the methods every route matching the path answers to
+	// when none answers the request method, it is empty when the path
+	// itself is unknown.
+	Allowed []string
+	// MaxBodyBytes is the closest body limit override along the path, zero
+	// when the server default applies.
+	MaxBodyBytes int64
 }
 
+// chainMiddleware wraps handler so the middlewares run in order before it.
 func chainMiddleware(handler func(request *httpx.HttpRequest, response *httpx.HttpResponse), middlewares []func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())) func(request *httpx.HttpRequest, response *httpx.HttpResponse) {
 	for i := len(middlewares) - 1; i >= 0; i-- {
 		current := middlewares[i]
@@ -43,19 +77,97 @@ func chainMiddleware(handler func(request *httpx.HttpRequest, response *httpx.Ht
 	return handler
 }
 
-func extractParam(segment string) (paramName string, isParam bool) {
-	isParam = strings.HasPrefix(segment, ":")
-	if isParam && len(segment) > 1 {
+// namedConstraints are the shorthands accepted in "{name:constraint}", any
+// other constraint is compiled as a regular expression.
+var namedConstraints = map[string]string{
+	"int":  `-?[0-9]+`,
+	"uuid": `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// extractParam recognises ":name", "{name}" and "{name:constraint}", named
+// constraints come back as their expression so {id:int} and {n:-?[0-9]+}
+// land on the same node.
+func extractParam(segment string) (paramName string, constraint string, isParam bool) {
+	if strings.HasPrefix(segment, ":") {
 		paramName = segment[1:]
-	} else if isParam {
-		isParam = false
+		return paramName, "", paramName != ""
+	}
+
+	if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+		return "", "", false
+	}
+
+	paramName, constraint, _ = strings.Cut(segment[1:len(segment)-1], ":")
+	if expr, ok := namedConstraints[constraint]; ok {
+		constraint = expr
+	}
+	return paramName, constraint, paramName != ""
+}
+
+func compileConstraint(constraint string) (*regexp.Regexp, error) {
+	if constraint == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + constraint + ")$")
+}
+
+// accepts reports whether a param node takes the raw segment, constraints
+// are checked against the decoded value.
+func (node *HttpMuxTrieNode) accepts(segment string) bool {
+	if node.matcher == nil {
+		return true
+	}
+
+	if decoded, err := url.PathUnescape(segment); err == nil {
+		segment = decoded
+	}
+	return node.matcher.MatchString(segment)
+}
+
+func (node *HttpMuxTrieNode) findParamChild(constraint string) *HttpMuxTrieNode {
+	for _, child := range node.paramChildren {
+		if child.constraint == constraint {
+			return child
+		}
 	}
-	return paramName, isParam
+	return nil
 }
 
-func newMuxTrieNode(pathSegment string) *HttpMuxTrieNode {
+func (node *HttpMuxTrieNode) addParamChild(child *HttpMuxTrieNode) {
+	if child.constraint == "" {
+		node.paramChildren = append(node.paramChildren, child)
+		return
+	}
+
+	// keep the unconstrained param last so it only catches what the
+	// constrained ones refuse
+	i := len(node.paramChildren)
+	if i > 0 && node.paramChildren[i-1].constraint == "" {
+		i--
+	}
+	node.paramChildren = append(node.paramChildren[:i], append([]*HttpMuxTrieNode{child}, node.paramChildren[i:]...)...)
+}
+
+// extractCatchAll recognises "*name", which needs at least one more segment,
+// and "*name?", which also matches an empty remainder.
+func extractCatchAll(segment string) (paramName string, optional bool, isCatchAll bool) {
+	if !strings.HasPrefix(segment, "*") {
+		return "", false, false
+	}
+
+	paramName = segment[1:]
+	if strings.HasSuffix(paramName, "?") {
+		paramName = paramName[:len(paramName)-1]
+		optional = true
+	}
+	return paramName, optional, paramName != ""
+}
+
+func newMuxTrieNode(pathSegment string, pattern string) *HttpMuxTrieNode {
 	return &HttpMuxTrieNode{
 		segment:        pathSegment,
+		pattern:        pattern,
+		patterns:       make(map[string]string),
 		staticChildren: make(map[string]*HttpMuxTrieNode),
 		handler:        make(map[string]func(request *httpx.HttpRequest, response *httpx.HttpResponse)),
 	}
@@ -63,7 +175,7 @@ func newMuxTrieNode(pathSegment string) *HttpMuxTrieNode {
 
 func NewHttpMux() *HttpMux {
 	return &HttpMux{
-		muxTrieRoot: newMuxTrieNode("*"),
+		muxTrieRoot: newMuxTrieNode("*", "/"),
 	}
 }
 
@@ -72,26 +184,59 @@ func (m *HttpMux) GetMuxTrieRoot() *HttpMuxTrieNode {
 }
 
 func (m *HttpMux) ExplorePath(method string, segments []string) RouteMatch {
-	node := m.muxTrieRoot
 	match := RouteMatch{
-		Middlewares: append([]func(*httpx.HttpRequest, *httpx.HttpResponse, func()){}, node.middlewares...),
-		Params:      map[string]string{},
-	}
-
-	for _, segment := range segments {
-		if next, ok := node.staticChildren[segment]; ok {
-			node = next
-			match.Middlewares = append(match.Middlewares, node.middlewares...)
-		} else if node.paramChild != nil {
-			node = node.paramChild
-			match.Params[node.paramName] = segment
-			match.Middlewares = append(match.Middlewares, node.middlewares...)
-		} else {
+		Params: map[string]string{},
+	}
+
+	steps := findRoute(segments, m.rootSteps(segments), func(steps []routeStep) bool {
+		return lookupHandler(steps[len(steps)-1].node, method) != nil
+	})
+	if steps == nil {
+		// no route answers the method, every route matching the path for
+		// another method makes it a 405 and adds to Allow. The first one
+		// still provides the middlewares
+		seen := map[string]bool{}
+		findRoute(segments, m.rootSteps(segments), func(candidate []routeStep) bool {
+			allowed := allowedMethods(candidate[len(candidate)-1].node)
+			if steps == nil && len(allowed) > 0 {
+				steps = append([]routeStep(nil), candidate...)
+			}
+			for _, method := range allowed {
+				seen[method] = true
+			}
+			// refuse every route so the search visits all alternatives
+			return false
+		})
+		if steps == nil {
 			return match
 		}
+
+		for method := range seen {
+			match.Allowed = append(match.Allowed, method)
+		}
+		sort.Strings(match.Allowed)
 	}
 
-	handler := node.handler[method]
+	var node *HttpMuxTrieNode
+	for _, step := range steps {
+		node = step.node
+		match.Middlewares = append(match.Middlewares, node.middlewares...)
+		if node.paramName != "" {
+			match.Params[node.paramName] = step.value
+		}
+		if node.maxBodyBytes != 0 {
+			match.MaxBodyBytes = node.maxBodyBytes
+		}
+	}
+
+	if node.mounted != nil {
+		rest := strings.Split(steps[len(steps)-1].value, "/")
+		if limit := node.mounted.ExplorePath(method, rest).MaxBodyBytes; limit != 0 {
+			match.MaxBodyBytes = limit
+		}
+	}
+
+	handler := lookupHandler(node, method)
 	if handler == nil {
 		return match
 	}
@@ -101,20 +246,255 @@ func (m *HttpMux) ExplorePath(method string, segments []string) RouteMatch {
 	return match
 }
 
-func (m *HttpMux) RegisterRoute(path string, method string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
-	// validate path
+// routeStep is one node on a matched route with the raw path it captured.
+type routeStep struct {
+	node  *HttpMuxTrieNode
+	value string
+}
+
+// rootSteps starts a search at the root, with room for one step per segment
+// and the optional catch-all after them.
+func (m *HttpMux) rootSteps(segments []string) []routeStep {
+	steps := make([]routeStep, 1, len(segments)+2)
+	steps[0].node = m.muxTrieRoot
+	return steps
+}
+
+// findRoute walks segments below the last of steps depth first and returns
+// the steps leading to the first route accepted, or nil. Static children are
+// tried before the params and the params before the catch-all, a branch
+// that dead-ends or a param whose constraint refuses the segment backs out
+// to the next alternative.
+func findRoute(segments []string, steps []routeStep, accept func(steps []routeStep) bool) []routeStep {
+	node := steps[len(steps)-1].node
+
+	if len(segments) == 0 {
+		if accept(steps) {
+			return steps
+		}
+		if child := node.catchAllChild; child != nil && child.optional {
+			if steps := append(steps, routeStep{node: child}); accept(steps) {
+				return steps
+			}
+		}
+		return nil
+	}
+
+	segment := segments[0]
+	if child, ok := node.staticChildren[segment]; ok {
+		if found := findRoute(segments[1:], append(steps, routeStep{node: child}), accept); found != nil {
+			return found
+		}
+	}
+
+	for _, child := range node.paramChildren {
+		if !child.accepts(segment) {
+			continue
+		}
+		if found := findRoute(segments[1:], append(steps, routeStep{node: child, value: segment}), accept); found != nil {
+			return found
+		}
+	}
+
+	if child := node.catchAllChild; child != nil {
+		rest := strings.Join(segments, "/")
+		if rest != "" || child.optional {
+			if steps := append(steps, routeStep{node: child, value: rest}); accept(steps) {
+				return steps
+			}
+		}
+	}
+	return nil
+}
+
+func lookupHandler(node *HttpMuxTrieNode, method string) func(request *httpx.HttpRequest, response *httpx.HttpResponse) {
+	handler := node.handler[method]
+	if handler == nil && method == constants.HEAD {
+		// HEAD is GET without the body, the response drops the bytes
+		handler = node.handler[constants.GET]
+	}
+	if handler == nil {
+		handler = node.handler[constants.ANY]
+	}
+	return handler
+}
+
+// allowedMethods lists the methods registered on node for the Allow header,
+// HEAD comes along with GET and OPTIONS is always answered.
+func allowedMethods(node *HttpMuxTrieNode) []string {
+	if len(node.handler) == 0 {
+		return nil
+	}
+
+	methods := []string{}
+	for method := range node.handler {
+		if method != constants.ANY {
+			methods = append(methods, method)
+		}
+	}
+
+	if _, exists := node.handler[constants.GET]; exists {
+		if _, exists := node.handler[constants.HEAD]; !exists {
+			methods = append(methods, constants.HEAD)
+		}
+	}
+
+	if _, exists := node.handler[constants.OPTIONS]; !exists {
+		methods = append(methods, constants.OPTIONS)
+	}
+
+	sort.Strings(methods)
+	return methods
+}
+
+// allMethods collects every method registered anywhere in the trie, it
+// answers "OPTIONS *".
+func (m *HttpMux) allMethods() []string {
+	seen := map[string]bool{}
+	nodes := []*HttpMuxTrieNode{m.muxTrieRoot}
+
+	for len(nodes) > 0 {
+		node := nodes[len(nodes)-1]
+		nodes = nodes[:len(nodes)-1]
+
+		for _, method := range allowedMethods(node) {
+			seen[method] = true
+		}
+		for _, child := range node.staticChildren {
+			nodes = append(nodes, child)
+		}
+		nodes = append(nodes, node.paramChildren...)
+		if node.catchAllChild != nil {
+			nodes = append(nodes, node.catchAllChild)
+		}
+	}
+
+	methods := []string{constants.OPTIONS}
+	for method := range seen {
+		if method != constants.OPTIONS {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// methodNotAllowed answers a known path that has no handler for the request
+// method, OPTIONS gets the list of methods and anything else a 405.
+func methodNotAllowed(allowed []string) func(request *httpx.HttpRequest, response *httpx.HttpResponse) {
+	return func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+		res.SetHeader("Allow", strings.Join(allowed, ", "))
+
+		if req.Method == constants.OPTIONS {
+			res.Status(int(constants.StatusNoContent)).End()
+			return
+		}
+
+		res.Status(int(constants.StatusMethodNotAllowed)).Send([]byte("method not allowed"))
+	}
+}
+
+// ErrRouteConflict is wrapped by the errors RegisterRoute returns when a
+// route duplicates or can't be told apart from one registered before it.
+var ErrRouteConflict = errors.New("route conflict")
+
+// RegisterRoute adds handler for method on path, it fails on a duplicate
+// route and on params that would share a node under different names. Params
+// share a node when their constraints read the same once named ones are
+// expanded. Regular expressions that merely overlap, like [0-9]+ and
+// [0-9a-f]+, are not detected, the one registered first is tried first.
+func (m *HttpMux) RegisterRoute(path string, method string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) error {
+	node, err := m.insertPath(path)
+	if err != nil {
+		return err
+	}
+
+	if existing, exists := node.patterns[method]; exists {
+		return fmt.Errorf("%w: %s %s is already registered as %s %s", ErrRouteConflict, method, path, method, existing)
+	}
+
+	node.handler[method] = handler
+	node.patterns[method] = path
+	return nil
+}
+
+// MustRegisterRoute is RegisterRoute for routes set up at startup, it panics
+// on a conflict.
+func (m *HttpMux) MustRegisterRoute(path string, method string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	if err := m.RegisterRoute(path, method, handler); err != nil {
+		panic(err)
+	}
+}
+
+// SetBodyLimit overrides the server body limit for path and everything below
+// it, a negative maxBytes lifts the limit.
+func (m *HttpMux) SetBodyLimit(path string, maxBytes int64) error {
+	node, err := m.insertPath(path)
+	if err != nil {
+		return err
+	}
+
+	node.maxBodyBytes = maxBytes
+	return nil
+}
+
+// SetMounted records that sub serves the catch-all at path, ExplorePath then
+// looks up the body limit sub sets for the rest of the path.
+func (m *HttpMux) SetMounted(path string, sub *HttpMux) error {
+	node, err := m.insertPath(path)
+	if err != nil {
+		return err
+	}
+
+	if _, _, isCatchAll := extractCatchAll(node.segment); !isCatchAll {
+		return fmt.Errorf("mount path %s does not end in a catch-all", path)
+	}
+	node.mounted = sub
+	return nil
+}
+
+func (m *HttpMux) insertPath(path string) (*HttpMuxTrieNode, error) {
 	pathParts := strings.Split(strings.Trim(path, "/"), "/")
 	currentNode := m.muxTrieRoot
 
-	for _, pathSegment := range pathParts {
-		paramName, isParam := extractParam(pathSegment)
+	for i, pathSegment := range pathParts {
+		if paramName, optional, isCatchAll := extractCatchAll(pathSegment); isCatchAll {
+			if i != len(pathParts)-1 {
+				return nil, fmt.Errorf("catch-all segment %s must be the last one in path %s", pathSegment, path)
+			}
+
+			child := currentNode.catchAllChild
+			if child == nil {
+				child = newMuxTrieNode(pathSegment, path)
+				child.paramName = paramName
+				child.optional = optional
+				currentNode.catchAllChild = child
+			} else if child.paramName != paramName || child.optional != optional {
+				// the empty remainder can't be matched for some routes only
+				return nil, fmt.Errorf("%w: catch-all %s in %s is ambiguous with %s in %s", ErrRouteConflict, pathSegment, path, child.segment, child.pattern)
+			}
+			currentNode = child
+			continue
+		}
+
+		paramName, constraint, isParam := extractParam(pathSegment)
 		if isParam {
 			log.Printf("registering route with path name %s gotten from segment %s", paramName, pathSegment)
-			child := currentNode.paramChild
+			child := currentNode.findParamChild(constraint)
 			if child == nil {
-				child = newMuxTrieNode(pathSegment)
+				matcher, err := compileConstraint(constraint)
+				if err != nil {
+					return nil, fmt.Errorf("invalid constraint in segment %s of path %s: %w", pathSegment, path, err)
+				}
+
+				child = newMuxTrieNode(pathSegment, path)
 				child.paramName = paramName
-				currentNode.paramChild = child
+				child.constraint = constraint
+				child.matcher = matcher
+				currentNode.addParamChild(child)
+			} else if child.paramName != paramName {
+				// both would match the same segments, only one name can win
+				return nil, fmt.Errorf("%w: param %s in %s is ambiguous with %s in %s", ErrRouteConflict, pathSegment, path, child.segment, child.pattern)
 			}
 			currentNode = child
 		} else {
@@ -124,50 +504,76 @@ func (m *HttpMux) RegisterRoute(path string, method string, handler func(request
 
 			child, exists := currentNode.staticChildren[pathSegment]
 			if !exists {
-				child = newMuxTrieNode(pathSegment)
+				child = newMuxTrieNode(pathSegment, path)
 				currentNode.staticChildren[pathSegment] = child
 			}
 			currentNode = child
 		}
 	}
-	currentNode.handler[method] = handler
+	return currentNode, nil
 }
 
 func (m *HttpMux) RouteRequest(req *httpx.HttpRequest, res *httpx.HttpResponse) {
-	match := m.ExplorePath(req.Method, req.PathParts)
+	m.ServeMatch(req, res, m.ExplorePath(req.Method, req.PathParts))
+}
 
+// ServeMatch runs a match ExplorePath already returned for req, the server
+// uses it so the route is only looked up once per request.
+func (m *HttpMux) ServeMatch(req *httpx.HttpRequest, res *httpx.HttpResponse, match RouteMatch) {
 	if !match.Found {
-		fmt.Println("Handler not found for", req.Method, req.URL)
-		res.Status(404).Send([]byte("page not found"))
-		return
+		if req.Method == constants.OPTIONS && req.RawPath == "*" {
+			methodNotAllowed(m.allMethods())(req, res)
+			return
+		}
+
+		if len(match.Allowed) == 0 {
+			fmt.Println("Handler not found for", req.Method, req.URL)
+			res.Status(404).Send([]byte("page not found"))
+			return
+		}
+
+		// middlewares along the path still run, a CORS middleware for
+		// instance wants to decorate the OPTIONS answer
+		match.Handler = methodNotAllowed(match.Allowed)
+	}
+
+	// params are matched against the raw path and only decoded afterwards,
+	// the parser already rejected malformed escapes
+	for name, value := range match.Params {
+		if decoded, err := url.PathUnescape(value); err == nil {
+			match.Params[name] = decoded
+		}
 	}
 
-	log.Printf("matched params: ", match.Params)
+	// a mux mounted under another one keeps the params captured on the way
+	// to the mount point
+	for name, value := range req.Params {
+		if _, exists := match.Params[name]; !exists {
+			match.Params[name] = value
+		}
+	}
+
+	log.Printf("matched params: %v", match.Params)
 	req.Params = match.Params
 
 	finalHandler := chainMiddleware(match.Handler, match.Middlewares)
 	finalHandler(req, res)
 }
 
-func (m *HttpMux) AttachMiddleware(path string, mw func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())) {
-	pathParts := strings.Split(path, "/")
-	currentNode := m.muxTrieRoot
-	for _, pathSegment := range pathParts {
-		_, isParam := extractParam(pathSegment)
-		if isParam {
-			if currentNode.paramChild == nil {
-				fmt.Println("(Node not found) Could not attach middleware to path: ", path)
-				return
-			}
-			currentNode = currentNode.paramChild
-		} else {
-			val, exists := currentNode.staticChildren[pathSegment]
-			if !exists {
-				fmt.Println("(Node not found) Could not attach middleware to path: ", path)
-				return
-			}
-			currentNode = val
-		}
+// AttachMiddleware runs mw for every route at or below path, "/" covering
+// them all. The path is created if no route reached it yet, so middlewares
+// can be attached before the routes they guard are registered.
+func (m *HttpMux) AttachMiddleware(path string, mw func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())) error {
+	if strings.Trim(path, "/") == "" {
+		m.muxTrieRoot.middlewares = append(m.muxTrieRoot.middlewares, mw)
+		return nil
+	}
+
+	node, err := m.insertPath(path)
+	if err != nil {
+		return err
 	}
-	currentNode.middlewares = append(currentNode.middlewares, mw)
+
+	node.middlewares = append(node.middlewares, mw)
+	return nil
 }
@@ -0,0 +1,286 @@
+package mux
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+)
+
+func segmentsOf(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// newTestMux registers every route for method with a handler that records
+// its pattern in got.
+func newTestMux(t testing.TB, method string, routes []string, got *string) *HttpMux {
+	m := NewHttpMux()
+	for _, route := range routes {
+		route := route
+		err := m.RegisterRoute(route, method, func(*httpx.HttpRequest, *httpx.HttpResponse) {
+			*got = route
+		})
+		if err != nil {
+			t.Fatalf("RegisterRoute(%q): %v", route, err)
+		}
+	}
+	return m
+}
+
+func TestExplorePathPriority(t *testing.T) {
+	routes := []string{
+		"/users/new",
+		"/users/new/edit",
+		"/users/:id",
+		"/users/:id/profile",
+		"/users/*rest",
+		"/items/{id:int}",
+		"/items/{slug:[a-z-]+}",
+		"/items/:other",
+		"/docs/*path?",
+		"/static/*file",
+	}
+
+	tests := []struct {
+		path   string
+		route  string
+		params map[string]string
+	}{
+		{"/users/new", "/users/new", map[string]string{}},
+		{"/users/42", "/users/:id", map[string]string{"id": "42"}},
+		{"/users/new/edit", "/users/new/edit", map[string]string{}},
+		{"/users/new/profile", "/users/:id/profile", map[string]string{"id": "new"}},
+		{"/users/new/other", "/users/*rest", map[string]string{"rest": "new/other"}},
+		{"/users/1/2/3", "/users/*rest", map[string]string{"rest": "1/2/3"}},
+		{"/items/7", "/items/{id:int}", map[string]string{"id": "7"}},
+		{"/items/hello-world", "/items/{slug:[a-z-]+}", map[string]string{"slug": "hello-world"}},
+		{"/items/Hello", "/items/:other", map[string]string{"other": "Hello"}},
+		{"/docs", "/docs/*path?", map[string]string{"path": ""}},
+		{"/docs/a/b", "/docs/*path?", map[string]string{"path": "a/b"}},
+		{"/static/app.js", "/static/*file", map[string]string{"file": "app.js"}},
+		{"/static", "", nil},
+		{"/nothing", "", nil},
+	}
+
+	var got string
+	m := newTestMux(t, "GET", routes, &got)
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got = ""
+			match := m.ExplorePath("GET", segmentsOf(tt.path))
+
+			if tt.route == "" {
+				if match.Found {
+					t.Fatalf("expected no match, got params %v", match.Params)
+				}
+				return
+			}
+
+			if !match.Found {
+				t.Fatalf("expected %s to match", tt.route)
+			}
+			match.Handler(nil, nil)
+			if got != tt.route {
+				t.Errorf("matched %s, want %s", got, tt.route)
+			}
+			if !reflect.DeepEqual(match.Params, tt.params) {
+				t.Errorf("params %v, want %v", match.Params, tt.params)
+			}
+		})
+	}
+}
+
+func TestExplorePathAllowed(t *testing.T) {
+	noop := func(*httpx.HttpRequest, *httpx.HttpResponse) {}
+
+	m := NewHttpMux()
+	m.MustRegisterRoute("/users/new", "POST", noop)
+	m.MustRegisterRoute("/users/:id", "GET", noop)
+	m.MustRegisterRoute("/users/*rest", "PATCH", noop)
+
+	tests := []struct {
+		method  string
+		path    string
+		found   bool
+		allowed []string
+	}{
+		{"GET", "/users/new", true, nil},
+		{"HEAD", "/users/new", true, nil},
+		{"DELETE", "/users/new", false, []string{"GET", "HEAD", "OPTIONS", "PATCH", "POST"}},
+		{"OPTIONS", "/users/new", false, []string{"GET", "HEAD", "OPTIONS", "PATCH", "POST"}},
+		{"DELETE", "/users/7", false, []string{"GET", "HEAD", "OPTIONS", "PATCH"}},
+		{"GET", "/other", false, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			match := m.ExplorePath(tt.method, segmentsOf(tt.path))
+			if match.Found != tt.found {
+				t.Fatalf("found %v, want %v", match.Found, tt.found)
+			}
+			if len(match.Allowed) != len(tt.allowed) || len(tt.allowed) > 0 && !reflect.DeepEqual(match.Allowed, tt.allowed) {
+				t.Errorf("allowed %v, want %v", match.Allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestRegisterRouteConflicts(t *testing.T) {
+	tests := []struct {
+		existing string
+		route    string
+		conflict bool
+	}{
+		{"/a/:id", "/a/:id", true},
+		{"/a/:id", "/a/{id}", true},
+		{"/a/:id", "/a/:name", true},
+		{"/a/{id:int}", "/a/{n:-?[0-9]+}", true},
+		{"/a/{id:int}", "/a/{id:int}/b", false},
+		{"/a/{id:int}", "/a/{slug:[a-z]+}", false},
+		{"/a/*rest", "/a/*path", true},
+		{"/a/*rest", "/a/*rest?", true},
+		{"/a/:id", "/a/*rest", false},
+		{"/a/b", "/a/:id", false},
+	}
+
+	noop := func(*httpx.HttpRequest, *httpx.HttpResponse) {}
+	for _, tt := range tests {
+		t.Run(tt.existing+" "+tt.route, func(t *testing.T) {
+			m := NewHttpMux()
+			m.MustRegisterRoute(tt.existing, "GET", noop)
+
+			err := m.RegisterRoute(tt.route, "GET", noop)
+			if got := errors.Is(err, ErrRouteConflict); got != tt.conflict {
+				t.Errorf("conflict %v (%v), want %v", got, err, tt.conflict)
+			}
+			if tt.conflict && (!strings.Contains(err.Error(), tt.existing) || !strings.Contains(err.Error(), tt.route)) {
+				t.Errorf("error %q does not name both patterns", err)
+			}
+		})
+	}
+}
+
+func TestExplorePathMountedBodyLimit(t *testing.T) {
+	noop := func(*httpx.HttpRequest, *httpx.HttpResponse) {}
+
+	sub := NewHttpMux()
+	sub.MustRegisterRoute("/upload/:name", "POST", noop)
+	sub.MustRegisterRoute("/other", "POST", noop)
+	if err := sub.SetBodyLimit("/upload", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewHttpMux()
+	m.MustRegisterRoute("/mod/*rest?", "*", noop)
+	if err := m.SetBodyLimit("/mod", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetMounted("/mod/*rest?", sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetMounted("/mod", sub); err == nil {
+		t.Error("SetMounted accepted a path without a catch-all")
+	}
+
+	tests := []struct {
+		path  string
+		limit int64
+	}{
+		{"/mod/upload/a", 10},
+		{"/mod/other", 100},
+		{"/mod", 100},
+	}
+	for _, tt := range tests {
+		if got := m.ExplorePath("POST", segmentsOf(tt.path)).MaxBodyBytes; got != tt.limit {
+			t.Errorf("%s: limit %d, want %d", tt.path, got, tt.limit)
+		}
+	}
+}
+
+func TestAttachMiddlewareBeforeRoutes(t *testing.T) {
+	noop := func(*httpx.HttpRequest, *httpx.HttpResponse) {}
+	mw := func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func()) { next() }
+
+	m := NewHttpMux()
+	for _, path := range []string{"/", "/api", "/api/users/:id"} {
+		if err := m.AttachMiddleware(path, mw); err != nil {
+			t.Fatalf("AttachMiddleware(%q): %v", path, err)
+		}
+	}
+	m.MustRegisterRoute("/api/items", "GET", noop)
+	m.MustRegisterRoute("/api/users/:id/posts", "GET", noop)
+	m.MustRegisterRoute("/other", "GET", noop)
+
+	tests := []struct {
+		path        string
+		middlewares int
+	}{
+		{"/api/items", 2},
+		{"/api/users/7/posts", 3},
+		{"/other", 1},
+	}
+	for _, tt := range tests {
+		if got := len(m.ExplorePath("GET", segmentsOf(tt.path)).Middlewares); got != tt.middlewares {
+			t.Errorf("%s: %d middlewares, want %d", tt.path, got, tt.middlewares)
+		}
+	}
+
+	if err := m.AttachMiddleware("/api/users/:name", mw); !errors.Is(err, ErrRouteConflict) {
+		t.Errorf("AttachMiddleware under a differently named param: %v, want a conflict", err)
+	}
+}
+
+func benchmarkExplorePath(b *testing.B, routes []string, path string) {
+	var got string
+	m := newTestMux(b, "GET", routes, &got)
+	segments := segmentsOf(path)
+
+	if !m.ExplorePath("GET", segments).Found {
+		b.Fatalf("%s does not match", path)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.ExplorePath("GET", segments)
+	}
+}
+
+func BenchmarkExplorePathStatic(b *testing.B) {
+	benchmarkExplorePath(b, []string{"/", "/api/v1/users", "/api/v1/users/:id", "/api/v1/items"}, "/api/v1/users")
+}
+
+func BenchmarkExplorePathParam(b *testing.B) {
+	benchmarkExplorePath(b, []string{"/", "/api/v1/users", "/api/v1/users/:id", "/api/v1/items"}, "/api/v1/users/42")
+}
+
+// the static branch only fails on the last segment, the search backs out to
+// the param on the second level
+func BenchmarkExplorePathDeepBacktrack(b *testing.B) {
+	benchmarkExplorePath(b, []string{"/a/b/c/d/e/f/g/x", "/a/:p/c/d/e/f/g/y"}, "/a/b/c/d/e/f/g/y")
+}
+
+// every level has a static and a param child and only the all-param route
+// matches, so the search visits every branch before it
+func BenchmarkExplorePathWorstCase(b *testing.B) {
+	const depth = 10
+
+	routes := []string{}
+	for static := 0; static <= depth; static++ {
+		parts := []string{}
+		for level := 0; level < depth; level++ {
+			if level < static {
+				parts = append(parts, "s")
+			} else {
+				parts = append(parts, ":p"+string(rune('a'+level)))
+			}
+		}
+		routes = append(routes, "/"+strings.Join(parts, "/")+"/x")
+	}
+	routes = append(routes, strings.TrimSuffix(routes[0], "/x")+"/y")
+
+	benchmarkExplorePath(b, routes, "/"+strings.Repeat("s/", depth)+"y")
+}
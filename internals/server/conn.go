@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var aLongTimeAgo = time.Unix(1, 0)
+
+// connReader sits between a connection and its bufio.Reader. While a handler
+// runs and the request body has been consumed it keeps a single byte read
+// pending on the socket, so a client hanging up cancels the request context.
+type connReader struct {
+	conn   net.Conn
+	onHup  func()
+	mu     sync.Mutex
+	cond   *sync.Cond
+	inRead bool
+	// aborted is set when abortPendingRead forced the background read to
+	// return, the resulting timeout must not count as a hang-up
+	aborted bool
+	hasByte bool
+	byteBuf [1]byte
+}
+
+func newConnReader(conn net.Conn, onHup func()) *connReader {
+	cr := &connReader{
+		conn:  conn,
+		onHup: onHup,
+	}
+	cr.cond = sync.NewCond(&cr.mu)
+	return cr
+}
+
+func (cr *connReader) Read(p []byte) (int, error) {
+	cr.mu.Lock()
+	if cr.inRead {
+		cr.mu.Unlock()
+		return 0, errors.New("concurrent read on connection")
+	}
+
+	if len(p) == 0 {
+		cr.mu.Unlock()
+		return 0, nil
+	}
+
+	if cr.hasByte {
+		p[0] = cr.byteBuf[0]
+		cr.hasByte = false
+		cr.mu.Unlock()
+		return 1, nil
+	}
+
+	cr.inRead = true
+	cr.mu.Unlock()
+
+	n, err := cr.conn.Read(p)
+
+	cr.mu.Lock()
+	cr.inRead = false
+	cr.mu.Unlock()
+	cr.cond.Broadcast()
+
+	return n, err
+}
+
+func (cr *connReader) startBackgroundRead() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if cr.inRead || cr.hasByte {
+		return
+	}
+
+	cr.inRead = true
+	cr.conn.SetReadDeadline(time.Time{})
+	go cr.backgroundRead()
+}
+
+func (cr *connReader) backgroundRead() {
+	n, err := cr.conn.Read(cr.byteBuf[:])
+
+	cr.mu.Lock()
+	if n == 1 {
+		cr.hasByte = true
+	}
+
+	var netErr net.Error
+	if err != nil && !(cr.aborted && errors.As(err, &netErr) && netErr.Timeout()) {
+		cr.onHup()
+	}
+
+	cr.aborted = false
+	cr.inRead = false
+	cr.mu.Unlock()
+	cr.cond.Broadcast()
+}
+
+// abortPendingRead stops a background read so the connection can be read
+// from again, it returns once the read has finished.
+func (cr *connReader) abortPendingRead() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if !cr.inRead {
+		return
+	}
+
+	cr.aborted = true
+	cr.conn.SetReadDeadline(aLongTimeAgo)
+	for cr.inRead {
+		cr.cond.Wait()
+	}
+	cr.conn.SetReadDeadline(time.Time{})
+}
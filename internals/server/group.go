@@ -0,0 +1,79 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+)
+
+// Group registers routes under a shared path prefix in the server trie. Its
+// middlewares are attached to the prefix itself, so they run for every route
+// below it, the automatic OPTIONS and 405 replies included.
+type Group struct {
+	server *Server
+	prefix string
+}
+
+// Group starts a route group under prefix, middlewares run for every route
+// below it after the ones attached with Server.Use on shorter paths.
+func (s *Server) Group(prefix string, middlewares ...func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())) *Group {
+	g := &Group{server: s, prefix: joinPath("", prefix)}
+	for _, mw := range middlewares {
+		s.Use(g.prefix, mw)
+	}
+	return g
+}
+
+// Group nests a group under this one, its routes run the outer middlewares
+// first.
+func (g *Group) Group(prefix string, middlewares ...func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())) *Group {
+	return g.server.Group(joinPath(g.prefix, prefix), middlewares...)
+}
+
+// Use attaches mw to the prefixed path like Server.Use, it runs for every
+// route below that path whether it came through the group or not.
+func (g *Group) Use(path string, mw func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())) {
+	g.server.Use(joinPath(g.prefix, path), mw)
+}
+
+// Handle registers handler for method on the prefixed path, it panics on a
+// conflicting route like Server.Handle.
+func (g *Group) Handle(method string, path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.server.Handle(method, joinPath(g.prefix, path), handler)
+}
+
+func (g *Group) Get(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.GET, path, handler)
+}
+func (g *Group) Post(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.POST, path, handler)
+}
+func (g *Group) Put(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.PUT, path, handler)
+}
+func (g *Group) Delete(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.DELETE, path, handler)
+}
+func (g *Group) Patch(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.PATCH, path, handler)
+}
+func (g *Group) Options(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.OPTIONS, path, handler)
+}
+func (g *Group) Head(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.HEAD, path, handler)
+}
+func (g *Group) Any(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.ANY, path, handler)
+}
+
+// BodyLimit overrides MaxBodyBytes for the prefixed path and everything below
+// it.
+func (g *Group) BodyLimit(path string, maxBytes int64) {
+	g.server.BodyLimit(joinPath(g.prefix, path), maxBytes)
+}
+
+func joinPath(prefix string, path string) string {
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.Trim(path, "/")
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+)
+
+func TestGroupUse(t *testing.T) {
+	_, addr := startServer(t, ServerConfig{}, func(s *Server) {
+		api := s.Group("/api")
+		api.Use("/", func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func()) {
+			res.SetHeader("X-Auth", "checked")
+			next()
+		})
+		api.Get("/items", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			res.Send([]byte("items"))
+		})
+		s.Get("/public", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			res.Send([]byte("public"))
+		})
+	})
+
+	tests := []struct {
+		path string
+		auth string
+	}{
+		{"/api/items", "checked"},
+		{"/public", ""},
+	}
+
+	conn, r := dial(t, addr)
+	for _, tt := range tests {
+		conn.Write([]byte("GET " + tt.path + " HTTP/1.1\r\nHost: a\r\n\r\n"))
+		res, _ := readResponse(t, r, "GET")
+		if got := res.Header.Get("X-Auth"); got != tt.auth {
+			t.Errorf("%s: X-Auth %q, want %q", tt.path, got, tt.auth)
+		}
+	}
+}
+
+func TestGroupMiddlewares(t *testing.T) {
+	// tag appends name to X-Trace so the order the middlewares ran in shows
+	tag := func(name string) func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func()) {
+		return func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func()) {
+			res.AddHeader("X-Trace", name)
+			next()
+		}
+	}
+
+	_, addr := startServer(t, ServerConfig{}, func(s *Server) {
+		api := s.Group("/api", tag("api"))
+		api.Get("/items", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			res.Send([]byte("items"))
+		})
+		v1 := api.Group("/v1", tag("v1"))
+		v1.Get("/users", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			res.Send([]byte("users"))
+		})
+		s.Get("/public", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			res.Send([]byte("public"))
+		})
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		trace  string
+	}{
+		{"GET", "/api/items", 200, "api"},
+		{"OPTIONS", "/api/items", 204, "api"},
+		{"DELETE", "/api/items", 405, "api"},
+		{"GET", "/api/v1/users", 200, "api,v1"},
+		{"GET", "/public", 200, ""},
+	}
+
+	conn, r := dial(t, addr)
+	for _, tt := range tests {
+		conn.Write([]byte(tt.method + " " + tt.path + " HTTP/1.1\r\nHost: a\r\n\r\n"))
+		res, _ := readResponse(t, r, tt.method)
+		if res.StatusCode != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, res.StatusCode, tt.status)
+		}
+		if got := strings.Join(res.Header.Values("X-Trace"), ","); got != tt.trace {
+			t.Errorf("%s %s: middlewares %q, want %q", tt.method, tt.path, got, tt.trace)
+		}
+	}
+}
+
+func TestUseConflictPanics(t *testing.T) {
+	s := CreateServerWithConfig(ServerConfig{})
+	s.Get("/users/:id", func(*httpx.HttpRequest, *httpx.HttpResponse) {})
+
+	defer func() {
+		if err, _ := recover().(error); err == nil || !strings.Contains(err.Error(), ":name") {
+			t.Errorf("recovered %v, want the conflict", err)
+		}
+	}()
+	s.Use("/users/:name", func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func()) { next() })
+}
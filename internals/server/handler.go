@@ -1,3 +1,314 @@
 package server
 
-func handleConnetion() {}
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+	"github.com/codecrafters-io/http-server-starter-go/internals/mux"
+	"github.com/codecrafters-io/http-server-starter-go/internals/util"
+)
+
+const DefaultIdleTimeout = 60 * time.Second
+
+const maxDrainBytes = 256 << 10
+
+// lingerTimeout bounds how long a closing connection keeps discarding what
+// the client still sends.
+const lingerTimeout = 500 * time.Millisecond
+
+func (s *Server) handleConnection(conn net.Conn) {
+	defer s.trackConn(conn, false)
+	defer conn.Close()
+
+	handshakeDeadline, _ := s.readDeadlines(time.Now())
+	conn.SetDeadline(handshakeDeadline)
+	tlsState, err := s.handshake(conn)
+	if err != nil {
+		log.Printf("TLS handshake error from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	connCtx, cancelConn := context.WithCancel(s.baseCtx)
+	defer cancelConn()
+
+	connReader := newConnReader(conn, cancelConn)
+	reader := bufio.NewReader(connReader)
+	writer := bufio.NewWriter(conn)
+	defer writer.Flush()
+
+	for served := 0; ; served++ {
+		// a fresh connection gets the header timeout from the moment it was
+		// accepted, keep-alive connections once the next request starts
+		start := time.Now()
+		headerDeadline, readDeadline := s.readDeadlines(start)
+
+		s.setConnIdle(conn, true)
+		if served == 0 {
+			conn.SetReadDeadline(headerDeadline)
+		} else {
+			conn.SetReadDeadline(s.idleDeadline(start))
+		}
+		if _, err := reader.Peek(1); err != nil {
+			return
+		}
+		s.setConnIdle(conn, false)
+
+		if served > 0 {
+			headerDeadline, readDeadline = s.readDeadlines(time.Now())
+			conn.SetReadDeadline(headerDeadline)
+		}
+
+		req, err := s.parseConn(reader)
+		conn.SetReadDeadline(readDeadline)
+		if s.config.WriteTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
+		} else {
+			conn.SetWriteDeadline(time.Time{})
+		}
+
+		res := httpx.NewResponse(writer)
+		var match mux.RouteMatch
+		if err == nil {
+			req.TLS = tlsState
+			if !req.ProtoAtLeast(1, 1) {
+				res.SetProtocol("HTTP/1.0")
+			}
+			if req.Method == constants.HEAD {
+				res.SuppressBody()
+			}
+			// a route override replaces the server limit, either way a
+			// declared length over it is turned down before the body is
+			// asked for
+			match = s.mux.ExplorePath(req.Method, req.PathParts)
+			limit := match.MaxBodyBytes
+			if limit == 0 {
+				limit = s.config.MaxBodyBytes
+			}
+			err = req.BodyStream.SetLimit(limit)
+		}
+
+		// with Expect: 100-continue the client holds the body back until we
+		// ask for it, which only happens once the handler starts reading so
+		// it can still turn the request down first
+		awaitingContinue := false
+		headWritten := false
+		if err == nil && req.ProtoAtLeast(1, 1) && req.Header.Has("Expect") {
+			if !strings.EqualFold(req.Header.Get("Expect"), "100-continue") {
+				err = util.NewRequestError(constants.StatusExpectationFailed, "unsupported expectation")
+			} else if req.BodyStream.HasBody() {
+				awaitingContinue = true
+				req.BodyStream.OnFirstRead(func() {
+					if !headWritten {
+						writer.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n\r\n", constants.StatusContinue, constants.StatusTexts[constants.StatusContinue]))
+						writer.Flush()
+					}
+					awaitingContinue = false
+				})
+			}
+		}
+
+		// whether the connection survives has to be settled before the head
+		// goes out. A body that was never asked for may or may not follow,
+		// and one too large to drain can't be skipped, so both cost the
+		// connection, as does a shutdown that started while the handler ran
+		if err == nil {
+			res.OnWriteHead(func() {
+				headWritten = true
+				if unread := req.BodyStream.Unread(); awaitingContinue || unread < 0 || unread > maxDrainBytes || s.shuttingDown() {
+					res.Header().Set("Connection", "close")
+				}
+			})
+		}
+
+		if err == nil && s.config.BufferBody {
+			_, err = req.ReadBody()
+		}
+		if err != nil {
+			writeParseError(res, err)
+			return
+		}
+
+		keepAlive := shouldKeepAlive(req) && !s.shuttingDown()
+		if s.config.MaxRequestsPerConn > 0 && served+1 >= s.config.MaxRequestsPerConn {
+			keepAlive = false
+		}
+
+		if !keepAlive {
+			res.SetHeader("Connection", "close")
+		} else if !req.ProtoAtLeast(1, 1) {
+			res.SetHeader("Connection", "keep-alive")
+		}
+
+		var ctx context.Context
+		var cancelReq context.CancelFunc
+		if s.config.HandlerTimeout > 0 {
+			ctx, cancelReq = context.WithTimeout(connCtx, s.config.HandlerTimeout)
+		} else {
+			ctx, cancelReq = context.WithCancel(connCtx)
+		}
+		req.WithContext(ctx)
+
+		// once the body is consumed nothing should arrive until the response
+		// is out, a read failing in the meantime means the client is gone
+		handlerDone := false
+		req.BodyStream.OnEOF(func() {
+			if !handlerDone {
+				connReader.startBackgroundRead()
+			}
+		})
+
+		s.mux.ServeMatch(req, res, match)
+
+		handlerDone = true
+		cancelReq()
+		connReader.abortPendingRead()
+		conn.SetReadDeadline(readDeadline)
+
+		// whatever the handler left unread has to be skipped before the next
+		// request, the head already announced a close if that can't work
+		bodyPending := false
+		if headerHasToken(res.GetHeader("Connection"), "close") {
+			bodyPending = req.BodyStream.Unread() != 0
+		} else if awaitingContinue || !req.BodyStream.Drain(maxDrainBytes) {
+			keepAlive = false
+			bodyPending = true
+			if !res.Sent() {
+				res.SetHeader("Connection", "close")
+			}
+		}
+
+		var reqErr *util.RequestError
+		if bodyErr := req.BodyStream.Err(); !res.Sent() && (errors.As(bodyErr, &reqErr) || isTimeout(bodyErr)) {
+			keepAlive = false
+			writeRequestError(res, bodyErr)
+		}
+
+		if !res.Sent() {
+			if err := res.End(); err != nil {
+				return
+			}
+		}
+
+		// pipelined requests already sitting in the read buffer are answered
+		// before touching the socket, responses stay in request order since
+		// they share one writer
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+
+		if !keepAlive || headerHasToken(res.GetHeader("Connection"), "close") || s.shuttingDown() {
+			if bodyPending && writer.Flush() == nil {
+				lingerClose(conn, reader)
+			}
+			return
+		}
+	}
+}
+
+// lingerClose half-closes conn and discards what the client still sends for
+// a moment. Closing with unread data makes the kernel reset the connection,
+// which can cut off the response before the client read it.
+func lingerClose(conn net.Conn, r io.Reader) {
+	closer, ok := conn.(interface{ CloseWrite() error })
+	if !ok || closer.CloseWrite() != nil {
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(lingerTimeout))
+	io.Copy(io.Discard, io.LimitReader(r, maxDrainBytes))
+}
+
+// readDeadlines returns when the request headers and the whole request must
+// have been read by, a zero time means no deadline.
+func (s *Server) readDeadlines(start time.Time) (header time.Time, read time.Time) {
+	if s.config.ReadTimeout > 0 {
+		read = start.Add(s.config.ReadTimeout)
+	}
+
+	header = read
+	if s.config.ReadHeaderTimeout > 0 {
+		headerTimeout := start.Add(s.config.ReadHeaderTimeout)
+		if header.IsZero() || headerTimeout.Before(header) {
+			header = headerTimeout
+		}
+	}
+	return header, read
+}
+
+func (s *Server) idleDeadline(start time.Time) time.Time {
+	idleTimeout := s.config.IdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+
+	if idleTimeout < 0 {
+		return time.Time{}
+	}
+	return start.Add(idleTimeout)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func writeParseError(res *httpx.HttpResponse, err error) {
+	// the client hung up before a full request arrived, nobody to answer
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return
+	}
+
+	writeRequestError(res, err)
+}
+
+// writeRequestError answers a request that could not be read with a generic
+// body and closes the connection, the details only go to the log.
+func writeRequestError(res *httpx.HttpResponse, err error) {
+	var reqErr *util.RequestError
+
+	status := constants.StatusInternalServerError
+	if errors.As(err, &reqErr) {
+		status = reqErr.StatusCode
+	} else if isTimeout(err) {
+		status = constants.StatusRequestTimeout
+	}
+
+	log.Printf("Failed to read request: %v", err)
+	res.SetHeader("Connection", "close")
+	res.Status(int(status)).Send([]byte(constants.StatusTexts[status]))
+}
+
+func shouldKeepAlive(req *httpx.HttpRequest) bool {
+	connection := strings.Join(req.Header.Values("Connection"), ",")
+
+	if headerHasToken(connection, "close") {
+		return false
+	}
+
+	if req.ProtoAtLeast(1, 1) {
+		return true
+	}
+
+	return headerHasToken(connection, "keep-alive")
+}
+
+func headerHasToken(value string, token string) bool {
+	for _, part := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+)
+
+func TestBodyLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBody    int64
+		routeLimit int64
+		length     int
+		expect     bool
+		status     int
+	}{
+		{"under server limit", 10, 0, 10, false, 200},
+		{"over server limit", 10, 0, 50, false, 413},
+		{"over server limit with expect", 10, 0, 50, true, 413},
+		{"under server limit with expect", 10, 0, 10, true, 200},
+		{"route raises the limit", 10, 100, 50, true, 200},
+		{"route lifts the limit", 10, -1, 50, false, 200},
+		{"route lowers the limit", 100, 10, 50, true, 413},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran atomic.Bool
+			_, addr := startServer(t, ServerConfig{MaxBodyBytes: tt.maxBody}, func(s *Server) {
+				s.Post("/upload", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					ran.Store(true)
+					body, err := req.ReadBody()
+					if err != nil {
+						return
+					}
+					res.Send([]byte(fmt.Sprint(len(body))))
+				})
+				if tt.routeLimit != 0 {
+					s.BodyLimit("/upload", tt.routeLimit)
+				}
+			})
+			conn, r := dial(t, addr)
+
+			head := fmt.Sprintf("POST /upload HTTP/1.1\r\nHost: a\r\nContent-Length: %d\r\n", tt.length)
+			body := strings.Repeat("x", tt.length)
+			if tt.expect {
+				conn.Write([]byte(head + "Expect: 100-continue\r\n\r\n"))
+
+				res, _ := readResponse(t, r, "POST")
+				if tt.status != 200 {
+					if res.StatusCode != tt.status {
+						t.Fatalf("status %d, want %d without 100 Continue", res.StatusCode, tt.status)
+					}
+					if ran.Load() {
+						t.Error("handler ran for a rejected body")
+					}
+					return
+				}
+				if res.StatusCode != 100 {
+					t.Fatalf("status %d, want 100 Continue", res.StatusCode)
+				}
+				conn.Write([]byte(body))
+			} else {
+				conn.Write([]byte(head + "\r\n" + body))
+			}
+
+			res, got := readResponse(t, r, "POST")
+			if res.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", res.StatusCode, tt.status)
+			}
+			if tt.status == 200 && got != fmt.Sprint(tt.length) {
+				t.Errorf("handler read %s bytes, want %d", got, tt.length)
+			}
+			if tt.status != 200 && ran.Load() {
+				t.Error("handler ran for a rejected body")
+			}
+		})
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	const get = "GET / HTTP/1.1\r\nHost: a\r\n\r\n"
+	const getClose = "GET / HTTP/1.1\r\nHost: a\r\nConnection: close\r\n\r\n"
+
+	tests := []struct {
+		name     string
+		config   ServerConfig
+		requests []string
+		// closed is whether the last response ends the connection
+		closed bool
+	}{
+		{"connection stays open", ServerConfig{}, []string{get, get, get}, false},
+		{"client asks to close", ServerConfig{}, []string{get, getClose}, true},
+		{"request limit reached", ServerConfig{MaxRequestsPerConn: 2}, []string{get, get}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, addr := startServer(t, tt.config, func(s *Server) {
+				s.Get("/", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					res.Send([]byte("hello"))
+				})
+			})
+			conn, r := dial(t, addr)
+
+			for i, raw := range tt.requests {
+				conn.Write([]byte(raw))
+				res, body := readResponse(t, r, "GET")
+				if res.StatusCode != 200 || body != "hello" {
+					t.Fatalf("request %d: %d %q", i, res.StatusCode, body)
+				}
+				if want := tt.closed && i == len(tt.requests)-1; res.Close != want {
+					t.Errorf("request %d: Connection: close %v, want %v", i, res.Close, want)
+				}
+			}
+
+			if tt.closed {
+				expectClosed(t, r)
+			}
+		})
+	}
+}
+
+func TestPipelining(t *testing.T) {
+	_, addr := startServer(t, ServerConfig{}, func(s *Server) {
+		s.Get("/slow", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			time.Sleep(20 * time.Millisecond)
+			res.Send([]byte("slow"))
+		})
+		s.Get("/fast", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			res.Send([]byte("fast"))
+		})
+		s.Post("/echo", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			body, _ := req.ReadBody()
+			res.Send(body)
+		})
+	})
+
+	tests := []struct {
+		method string
+		raw    string
+		body   string
+	}{
+		{"GET", "GET /slow HTTP/1.1\r\nHost: a\r\n\r\n", "slow"},
+		{"POST", "POST /echo HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nfirst", "first"},
+		{"GET", "GET /fast HTTP/1.1\r\nHost: a\r\n\r\n", "fast"},
+		{"POST", "POST /echo HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n6\r\nsecond\r\n0\r\n\r\n", "second"},
+		{"HEAD", "HEAD /fast HTTP/1.1\r\nHost: a\r\n\r\n", ""},
+		{"GET", "GET /fast HTTP/1.1\r\nHost: a\r\n\r\n", "fast"},
+	}
+
+	// every request goes out in one write before any response is read
+	var raw strings.Builder
+	for _, tt := range tests {
+		raw.WriteString(tt.raw)
+	}
+	conn, r := dial(t, addr)
+	conn.Write([]byte(raw.String()))
+
+	for i, tt := range tests {
+		res, body := readResponse(t, r, tt.method)
+		if res.StatusCode != 200 || body != tt.body {
+			t.Errorf("response %d: %d %q, want 200 %q", i, res.StatusCode, body, tt.body)
+		}
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name   string
+		config ServerConfig
+		send   string
+		// status is the response expected before the close, zero for none
+		status int
+	}{
+		{"connection sends nothing", ServerConfig{ReadHeaderTimeout: timeout}, "", 0},
+		{"headers too slow", ServerConfig{ReadHeaderTimeout: timeout}, "GET / HTTP/1.1\r\nHost: a\r\n", 408},
+		{"body too slow", ServerConfig{ReadTimeout: timeout}, "POST /upload HTTP/1.1\r\nHost: a\r\nContent-Length: 10\r\n\r\nhello", 408},
+		{"idle keep-alive connection", ServerConfig{IdleTimeout: timeout}, "GET / HTTP/1.1\r\nHost: a\r\n\r\n", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, addr := startServer(t, tt.config, func(s *Server) {
+				s.Get("/", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					res.Send([]byte("hello"))
+				})
+				s.Post("/upload", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					if _, err := req.ReadBody(); err == nil {
+						res.Send([]byte("uploaded"))
+					}
+				})
+			})
+			conn, r := dial(t, addr)
+			conn.Write([]byte(tt.send))
+
+			if tt.status != 0 {
+				res, _ := readResponse(t, r, "GET")
+				if res.StatusCode != tt.status {
+					t.Errorf("status %d, want %d", res.StatusCode, tt.status)
+				}
+			}
+			expectClosed(t, r)
+		})
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     ServerConfig
+		send       string
+		disconnect bool
+		err        error
+	}{
+		{"client disconnects", ServerConfig{}, "GET /wait HTTP/1.1\r\nHost: a\r\n\r\n", true, context.Canceled},
+		{"client disconnects after the body", ServerConfig{}, "POST /wait HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", true, context.Canceled},
+		{"handler timeout", ServerConfig{HandlerTimeout: 20 * time.Millisecond}, "GET /wait HTTP/1.1\r\nHost: a\r\n\r\n", false, context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			done := make(chan error, 1)
+
+			_, addr := startServer(t, tt.config, func(s *Server) {
+				s.Any("/wait", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					req.ReadBody()
+					close(started)
+					select {
+					case <-req.Context().Done():
+						done <- req.Context().Err()
+					case <-time.After(2 * time.Second):
+						done <- nil
+					}
+					res.Send([]byte("done"))
+				})
+			})
+			conn, _ := dial(t, addr)
+			conn.Write([]byte(tt.send))
+
+			<-started
+			if tt.disconnect {
+				conn.Close()
+			}
+			if err := <-done; err != tt.err {
+				t.Errorf("request context: %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		expect string
+		// sendBody is whether the client gets a 100 Continue and sends the body
+		sendBody bool
+		status   int
+		closed   bool
+	}{
+		{"handler reads the body", "/read", "100-continue", true, 200, false},
+		{"handler answers without reading", "/reject", "100-continue", false, 401, true},
+		{"unknown expectation", "/read", "something-else", false, 417, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, addr := startServer(t, ServerConfig{}, func(s *Server) {
+				s.Post("/read", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					body, _ := req.ReadBody()
+					res.Send(body)
+				})
+				s.Post("/reject", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					res.Status(401).Send([]byte("no"))
+				})
+			})
+			conn, r := dial(t, addr)
+			conn.Write([]byte("POST " + tt.path + " HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\nExpect: " + tt.expect + "\r\n\r\n"))
+
+			if tt.sendBody {
+				if res, _ := readResponse(t, r, "POST"); res.StatusCode != 100 {
+					t.Fatalf("status %d, want 100 Continue", res.StatusCode)
+				}
+				conn.Write([]byte("hello"))
+			}
+
+			res, _ := readResponse(t, r, "POST")
+			if res.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", res.StatusCode, tt.status)
+			}
+			if res.Close != tt.closed {
+				t.Errorf("Connection: close %v, want %v", res.Close, tt.closed)
+			}
+			if tt.closed {
+				expectClosed(t, r)
+			}
+		})
+	}
+}
+
+// a body too large to drain closes the connection, the client still gets the
+// whole response while it is sending
+func TestLingerClose(t *testing.T) {
+	_, addr := startServer(t, ServerConfig{}, func(s *Server) {
+		s.Post("/reject", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			res.Status(401).Send([]byte(strings.Repeat("no", 1<<10)))
+		})
+	})
+	conn, r := dial(t, addr)
+
+	body := strings.Repeat("x", 2*maxDrainBytes)
+	go conn.Write([]byte(fmt.Sprintf("POST /reject HTTP/1.1\r\nHost: a\r\nContent-Length: %d\r\n\r\n%s", len(body), body)))
+
+	res, got := readResponse(t, r, "POST")
+	if res.StatusCode != 401 || len(got) != 2<<10 || !res.Close {
+		t.Errorf("status %d, %d body bytes, Connection: close %v", res.StatusCode, len(got), res.Close)
+	}
+}
+
+func TestHTTP10(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		body      string
+		keepAlive bool
+	}{
+		{"plain request", "GET / HTTP/1.0\r\n\r\n", "hello", false},
+		{"keep-alive request", "GET / HTTP/1.0\r\nConnection: keep-alive\r\n\r\n", "hello", true},
+		{"streamed response", "GET /stream HTTP/1.0\r\n\r\n", "one,two", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, addr := startServer(t, ServerConfig{}, func(s *Server) {
+				s.Get("/", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					res.Send([]byte("hello"))
+				})
+				s.Get("/stream", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+					res.SetHeader("Transfer-Encoding", "chunked")
+					res.Write([]byte("one,"))
+					res.Write([]byte("two"))
+				})
+			})
+			conn, r := dial(t, addr)
+			conn.Write([]byte(tt.raw))
+
+			res, body := readResponse(t, r, "GET")
+			if res.Proto != "HTTP/1.0" || res.StatusCode != 200 || body != tt.body {
+				t.Fatalf("%s %d %q, want HTTP/1.0 200 %q", res.Proto, res.StatusCode, body, tt.body)
+			}
+			if len(res.TransferEncoding) > 0 || res.ContentLength != int64(len(tt.body)) {
+				t.Errorf("Transfer-Encoding %v, Content-Length %d", res.TransferEncoding, res.ContentLength)
+			}
+
+			if !tt.keepAlive {
+				expectClosed(t, r)
+				return
+			}
+			if res.Header.Get("Connection") != "keep-alive" {
+				t.Errorf("Connection %q, want keep-alive", res.Header.Get("Connection"))
+			}
+			conn.Write([]byte(tt.raw))
+			if _, body := readResponse(t, r, "GET"); body != tt.body {
+				t.Errorf("second response %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+	"github.com/codecrafters-io/http-server-starter-go/internals/mux"
+)
+
+// mountParam names the catch-all a mount registers, it is removed before the
+// mounted handler sees the params.
+const mountParam = "mountpath"
+
+// Mount hands every request under prefix to handler with the prefix stripped
+// from Path, RawPath and PathParts. Params captured in prefix and middlewares
+// attached along it with Use apply to the mounted routes as well. The server
+// can't see body limits behind a plain handler, mount a mux with MountMux so
+// its SetBodyLimit overrides still apply.
+func (s *Server) Mount(prefix string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	s.Handle(constants.ANY, mountPattern(prefix), mountHandler(prefix, handler))
+}
+
+// MountMux mounts sub under prefix like Mount, the body limits sub sets are
+// enforced for the requests it serves.
+func (s *Server) MountMux(prefix string, sub *mux.HttpMux) {
+	s.Mount(prefix, sub.RouteRequest)
+	if err := s.mux.SetMounted(mountPattern(prefix), sub); err != nil {
+		panic(err)
+	}
+}
+
+// Mount mounts handler under the prefixed path, the group middlewares run
+// before it.
+func (g *Group) Mount(prefix string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	g.Handle(constants.ANY, mountPattern(prefix), mountHandler(joinPath(g.prefix, prefix), handler))
+}
+
+// MountMux mounts sub under the prefixed path with its body limits, like
+// Server.MountMux.
+func (g *Group) MountMux(prefix string, sub *mux.HttpMux) {
+	g.Mount(prefix, sub.RouteRequest)
+	if err := g.server.mux.SetMounted(mountPattern(joinPath(g.prefix, prefix)), sub); err != nil {
+		panic(err)
+	}
+}
+
+func mountPattern(prefix string) string {
+	return joinPath(prefix, "*"+mountParam+"?")
+}
+
+func mountHandler(prefix string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) func(request *httpx.HttpRequest, response *httpx.HttpResponse) {
+	depth := 0
+	if trimmed := strings.Trim(prefix, "/"); trimmed != "" {
+		depth = len(strings.Split(trimmed, "/"))
+	}
+
+	return func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+		path, rawPath, pathParts := req.Path, req.RawPath, req.PathParts
+		delete(req.Params, mountParam)
+
+		// the prefix may hold params, so strip it by segment count
+		rest := []string{""}
+		if depth < len(pathParts) {
+			rest = pathParts[depth:]
+		}
+		req.PathParts = rest
+		req.RawPath = "/" + strings.Join(rest, "/")
+		if decoded, err := url.PathUnescape(req.RawPath); err == nil {
+			req.Path = decoded
+		}
+
+		// middlewares wrapping the mount see the original path once it returns
+		defer func() {
+			req.Path, req.RawPath, req.PathParts = path, rawPath, pathParts
+		}()
+		handler(req, res)
+	}
+}
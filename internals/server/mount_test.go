@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+	"github.com/codecrafters-io/http-server-starter-go/internals/mux"
+)
+
+func TestMount(t *testing.T) {
+	// describe answers with what the mounted handler saw
+	describe := func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+		res.Send([]byte(fmt.Sprintf("%s %s %v", req.Path, req.RawPath, req.Params)))
+	}
+
+	sub := mux.NewHttpMux()
+	sub.MustRegisterRoute("/items/:id", "GET", describe)
+	sub.MustRegisterRoute("/upload", "POST", describe)
+	if err := sub.SetBodyLimit("/upload", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	_, addr := startServer(t, ServerConfig{}, func(s *Server) {
+		s.Mount("/users/:user/files", describe)
+		s.MountMux("/orgs/:org/api", sub)
+	})
+
+	tests := []struct {
+		name   string
+		raw    string
+		status int
+		body   string
+	}{
+		{"path below the prefix", "GET /users/ann/files/a/b.txt HTTP/1.1\r\nHost: a\r\n\r\n", 200, "/a/b.txt /a/b.txt map[user:ann]"},
+		{"prefix itself", "GET /users/ann/files HTTP/1.1\r\nHost: a\r\n\r\n", 200, "/ / map[user:ann]"},
+		{"escaped slash", "GET /users/ann/files/a%2Fb HTTP/1.1\r\nHost: a\r\n\r\n", 200, "/a/b /a%2Fb map[user:ann]"},
+		{"mounted mux route", "GET /orgs/acme/api/items/7 HTTP/1.1\r\nHost: a\r\n\r\n", 200, "/items/7 /items/7 map[id:7 org:acme]"},
+		{"mounted mux miss", "GET /orgs/acme/api/other HTTP/1.1\r\nHost: a\r\n\r\n", 404, "page not found"},
+		{"mounted mux body limit", "POST /orgs/acme/api/upload HTTP/1.1\r\nHost: a\r\nContent-Length: 10\r\n\r\n0123456789", 413, "Request Entity Too Large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, r := dial(t, addr)
+			conn.Write([]byte(tt.raw))
+
+			method, _, _ := strings.Cut(tt.raw, " ")
+			res, body := readResponse(t, r, method)
+			if res.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", res.StatusCode, tt.status)
+			}
+			if body != tt.body {
+				t.Errorf("handler saw %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
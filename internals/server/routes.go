@@ -1,19 +1,66 @@
 package server
 
 import (
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
 	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+	"github.com/codecrafters-io/http-server-starter-go/internals/mux"
 )
 
-func (s *Server) Get(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
-	s.mux.RegisterRoute(path, "GET", handler)
+// Router is the registration API Server and Group share, code that sets up
+// routes can take either.
+type Router interface {
+	Handle(method string, path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Get(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Post(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Put(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Delete(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Patch(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Options(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Head(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Any(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	Use(path string, mw func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func()))
+	BodyLimit(path string, maxBytes int64)
+	Group(prefix string, middlewares ...func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())) *Group
+	Mount(prefix string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse))
+	MountMux(prefix string, sub *mux.HttpMux)
+}
+
+var (
+	_ Router = (*Server)(nil)
+	_ Router = (*Group)(nil)
+)
+
+// Handle registers handler for any method token, including extension
+// methods such as PROPFIND or PURGE. It panics when the route conflicts with
+// one registered before it.
+func (s *Server) Handle(method string, path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	s.mux.MustRegisterRoute(path, method, handler)
+}
 
+func (s *Server) Get(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	s.Handle(constants.GET, path, handler)
 }
 func (s *Server) Post(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
-	s.mux.RegisterRoute(path, "POST", handler)
+	s.Handle(constants.POST, path, handler)
 }
 func (s *Server) Put(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
-	s.mux.RegisterRoute(path, "PUT", handler)
+	s.Handle(constants.PUT, path, handler)
 }
 func (s *Server) Delete(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
-	s.mux.RegisterRoute(path, "DELETE", handler)
+	s.Handle(constants.DELETE, path, handler)
+}
+func (s *Server) Patch(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	s.Handle(constants.PATCH, path, handler)
+}
+func (s *Server) Options(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	s.Handle(constants.OPTIONS, path, handler)
+}
+func (s *Server) Head(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	s.Handle(constants.HEAD, path, handler)
+}
+
+// Any registers handler for every method that has no handler of its own on
+// path.
+func (s *Server) Any(path string, handler func(request *httpx.HttpRequest, response *httpx.HttpResponse)) {
+	s.Handle(constants.ANY, path, handler)
 }
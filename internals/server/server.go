@@ -1,10 +1,15 @@
 package server
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
 	"github.com/codecrafters-io/http-server-starter-go/internals/mux"
@@ -13,10 +18,60 @@ import (
 
 type RouteMethod func(path string, handler *func(request *httpx.HttpRequest, response *httpx.HttpResponse))
 type Server struct {
-	mux mux.HttpMux
+	mux    mux.HttpMux
+	config ServerConfig
+
+	baseCtx    context.Context
+	cancelBase context.CancelFunc
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]bool
+	inShutdown atomic.Bool
+}
+
+var ErrServerClosed = errors.New("server closed")
+
+type ServerConfig struct {
+	// MaxHeaderBytes caps the request line plus headers, defaults to 1MB.
+	MaxHeaderBytes int
+	// MaxHeaderCount caps the number of header lines, defaults to 100.
+	MaxHeaderCount int
+	// MaxURILength caps the request target, defaults to 8KB.
+	MaxURILength int
+	// MaxBodyBytes caps the request body, zero means no limit. Routes can
+	// override it with Server.BodyLimit.
+	MaxBodyBytes int64
+	// BufferBody reads the whole body into req.Body before the handler runs,
+	// otherwise handlers stream it through req.BodyReader().
+	BufferBody bool
+	// ReadHeaderTimeout bounds reading the request line and headers, it
+	// also covers a new connection that never sends anything.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds reading the whole request including the body.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds handling the request and writing the response,
+	// counted from the end of the request headers.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection waits for the next
+	// request, defaults to 60s and a negative value disables it.
+	IdleTimeout time.Duration
+	// MaxRequestsPerConn closes the connection after that many requests,
+	// zero means no limit.
+	MaxRequestsPerConn int
+	// HandlerTimeout cancels the request context once a handler has run for
+	// that long, zero means no limit.
+	HandlerTimeout time.Duration
+	// BaseContext is the parent of every request context, defaults to
+	// context.Background().
+	BaseContext context.Context
 }
 
 func (s *Server) Listen(port string, cb func()) error {
+	if s.shuttingDown() {
+		return ErrServerClosed
+	}
+
 	l, err := net.Listen("tcp", port)
 
 	if err != nil {
@@ -25,63 +80,188 @@ func (s *Server) Listen(port string, cb func()) error {
 		return errors.New(errorMessage)
 	}
 
+	return s.serve(l, cb)
+}
+
+// Serve accepts connections on l until the server is shut down, which makes
+// it possible to run on Unix sockets or listeners handed over by a
+// supervisor. Listen and ListenTLS are thin wrappers around it.
+func (s *Server) Serve(l net.Listener) error {
+	return s.serve(l, nil)
+}
+
+func (s *Server) serve(l net.Listener, cb func()) error {
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
 	defer l.Close()
 
-	fmt.Println("Server listening on port 4221...")
-	go cb()
+	fmt.Printf("Server listening on %s %s...\n", l.Addr().Network(), l.Addr().String())
+	if cb != nil {
+		go cb()
+	}
 
 	for {
 		conn, err := l.Accept()
 
 		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
 			fmt.Println("Error accepting connection: ", err.Error())
 			continue
 		}
 
-		go func() {
-			defer conn.Close()
+		s.trackConn(conn, true)
+		go s.handleConnection(conn)
+	}
 
-			req, err := s.parseConn(conn)
-			res := httpx.NewResponse(conn)
-			if err != nil {
-				res.Status(500).Send([]byte(fmt.Sprintf("Failed to parse request %s", err.Error())))
-				return
-			}
+}
+
+// Shutdown stops accepting connections, closes idle keep-alive connections
+// and waits for in-flight requests to finish, giving up when ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.inShutdown.Store(true)
+
+	s.mu.Lock()
+	for l := range s.listeners {
+		l.Close()
+		delete(s.listeners, l)
+	}
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if s.closeIdleConns() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			// out of time, tell the remaining handlers to give up
+			s.cancelBase()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) shuttingDown() bool {
+	return s.inShutdown.Load()
+}
+
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-			s.mux.RouteRequest(req, res)
-		}()
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
 	}
 
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+		s.listeners[l] = struct{}{}
+	} else {
+		delete(s.listeners, l)
+	}
+	return true
+}
+
+func (s *Server) trackConn(conn net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]bool)
+	}
+
+	if add {
+		s.conns[conn] = false
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+func (s *Server) setConnIdle(conn net.Conn, idle bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.conns[conn]; exists {
+		s.conns[conn] = idle
+	}
+}
+
+// closeIdleConns closes every connection waiting for its next request and
+// reports whether no connections are left.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn, idle := range s.conns {
+		if idle {
+			conn.Close()
+			delete(s.conns, conn)
+		}
+	}
+	return len(s.conns) == 0
+}
+
+// BodyLimit overrides MaxBodyBytes for path and everything below it, a
+// negative maxBytes lifts the limit.
+func (s *Server) BodyLimit(path string, maxBytes int64) {
+	if err := s.mux.SetBodyLimit(path, maxBytes); err != nil {
+		panic(err)
+	}
 }
 
 func (s *Server) Use(path string, mw func(req *httpx.HttpRequest, res *httpx.HttpResponse, next func())) {
-	s.mux.AttachMiddleware(path, mw)
+	if err := s.mux.AttachMiddleware(path, mw); err != nil {
+		panic(err)
+	}
 }
 
-func (s *Server) parseConn(conn net.Conn) (req *httpx.HttpRequest, err error) {
-	buff := make([]byte, 1024)
-	_, err = conn.Read(buff)
+func (s *Server) parseConn(r *bufio.Reader) (req *httpx.HttpRequest, err error) {
+	info, err := util.ReadRequest(r, util.ParseLimits{
+		MaxHeaderBytes: s.config.MaxHeaderBytes,
+		MaxHeaderCount: s.config.MaxHeaderCount,
+		MaxURILength:   s.config.MaxURILength,
+		MaxBodyBytes:   s.config.MaxBodyBytes,
+	})
 	if err != nil {
 		return req, err
 	}
 
-	info, extractUrlErr := util.ExtractUrl(buff)
-	if extractUrlErr != nil {
-		return req, extractUrlErr
-	}
-
-	segments := strings.Split(strings.Trim(info.Path, "/"), "/")
+	segments := strings.Split(strings.Trim(info.RawPath, "/"), "/")
 
 	req = &httpx.HttpRequest{
 		ParsedRequestInfo: *info,
-		URL:               info.Host + info.Path,
+		URL:               info.Host + info.RequestURI,
 		PathParts:         segments,
 	}
 	return req, nil
 }
 
 func CreateServer() *Server {
+	return CreateServerWithConfig(ServerConfig{})
+}
+
+func CreateServerWithConfig(config ServerConfig) *Server {
+	baseCtx := config.BaseContext
+	if baseCtx == nil {
+		baseCtx = context.Background()
+	}
+	baseCtx, cancelBase := context.WithCancel(baseCtx)
+
 	return &Server{
-		mux: *mux.NewHttpMux(),
+		mux:        *mux.NewHttpMux(),
+		config:     config,
+		baseCtx:    baseCtx,
+		cancelBase: cancelBase,
 	}
 }
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/httpx"
+)
+
+// startServer serves a server built from config on a loopback port, setup
+// registers its routes. The server is shut down when the test ends.
+func startServer(t *testing.T, config ServerConfig, setup func(s *Server)) (*Server, string) {
+	t.Helper()
+
+	s := CreateServerWithConfig(config)
+	setup(s)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go s.Serve(l)
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+	return s, l.Addr().String()
+}
+
+// dial opens a raw client connection that gives up after a few seconds, so a
+// server that never answers fails the test instead of hanging it.
+func dial(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return conn, bufio.NewReader(conn)
+}
+
+// readResponse reads one response to method off r, interim 1xx responses
+// included.
+func readResponse(t *testing.T, r *bufio.Reader, method string) (*http.Response, string) {
+	t.Helper()
+
+	res, err := http.ReadResponse(r, &http.Request{Method: method})
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("read response body: %v", err)
+	}
+	return res, string(body)
+}
+
+// expectClosed fails the test unless the server closed the connection
+// without sending anything more.
+func expectClosed(t *testing.T, r *bufio.Reader) {
+	t.Helper()
+
+	if b, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("connection still open, read %q, %v", b, err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s := CreateServerWithConfig(ServerConfig{})
+	s.Get("/", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+		res.Send([]byte("hello"))
+	})
+	s.Get("/slow", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+		close(started)
+		<-release
+		res.Send([]byte("done"))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(l) }()
+	addr := l.Addr().String()
+
+	idleConn, idleReader := dial(t, addr)
+	idleConn.Write([]byte("GET / HTTP/1.1\r\nHost: a\r\n\r\n"))
+	readResponse(t, idleReader, "GET")
+
+	busyConn, busyReader := dial(t, addr)
+	busyConn.Write([]byte("GET /slow HTTP/1.1\r\nHost: a\r\n\r\n"))
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+
+	// the idle connection goes right away, the busy one once it answered
+	expectClosed(t, idleReader)
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v with a request in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	res, body := readResponse(t, busyReader, "GET")
+	if body != "done" || !res.Close {
+		t.Errorf("in-flight response %q, Connection: close %v", body, res.Close)
+	}
+	expectClosed(t, busyReader)
+
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve returned %v, want ErrServerClosed", err)
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("listener still accepts connections")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+
+	s, addr := startServer(t, ServerConfig{}, func(s *Server) {
+		s.Get("/slow", func(req *httpx.HttpRequest, res *httpx.HttpResponse) {
+			close(started)
+			select {
+			case <-req.Context().Done():
+				cancelled <- req.Context().Err()
+			case <-time.After(2 * time.Second):
+				cancelled <- nil
+			}
+		})
+	})
+
+	conn, _ := dial(t, addr)
+	conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: a\r\n\r\n"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown: %v, want DeadlineExceeded", err)
+	}
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("request context: %v, want Canceled", err)
+	}
+}
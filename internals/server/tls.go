@@ -0,0 +1,66 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+)
+
+func (s *Server) ListenTLS(port string, certFile string, keyFile string, cb func()) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed to load TLS certificate %s", err.Error())
+		fmt.Println(errorMessage)
+		return errors.New(errorMessage)
+	}
+
+	return s.ListenTLSWithConfig(port, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}, cb)
+}
+
+func (s *Server) ListenTLSWithConfig(port string, config *tls.Config, cb func()) error {
+	if s.shuttingDown() {
+		return ErrServerClosed
+	}
+
+	if config == nil {
+		return errors.New("ListenTLSWithConfig needs a TLS config")
+	}
+	if len(config.Certificates) == 0 && config.GetCertificate == nil && config.GetConfigForClient == nil {
+		return errors.New("TLS config has no certificate")
+	}
+
+	config = config.Clone()
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+
+	l, err := net.Listen("tcp", port)
+
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed to bind to port %s %s", port, err.Error())
+		fmt.Println(errorMessage)
+		return errors.New(errorMessage)
+	}
+
+	return s.serve(tls.NewListener(l, config), cb)
+}
+
+// handshake completes the TLS handshake up front so a failed handshake never
+// reaches the request loop and the negotiated state can be handed to
+// handlers, plain connections return a nil state.
+func (s *Server) handshake(conn net.Conn) (*tls.ConnectionState, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil, nil
+	}
+
+	if err := tlsConn.HandshakeContext(s.baseCtx); err != nil {
+		return nil, err
+	}
+
+	state := tlsConn.ConnectionState()
+	return &state, nil
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"errors"
+	"io"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+)
+
+var ErrBodyReadAfterClose = errors.New("read on closed request body")
+
+// BodyReader streams a request body straight from the connection with its
+// Content-Length or chunked framing applied.
+type BodyReader struct {
+	r        io.Reader
+	expected int64
+	read     int64
+	max      int64
+	closed   bool
+	err      error
+	onEOF    func()
+	onRead   func()
+}
+
+// newBodyReader wraps r, expected is the declared Content-Length or -1 when
+// the body is chunked, max caps the body size with zero meaning no limit.
+func newBodyReader(r io.Reader, expected int64, max int64) *BodyReader {
+	if expected >= 0 {
+		r = io.LimitReader(r, expected)
+	}
+
+	return &BodyReader{
+		r:        r,
+		expected: expected,
+		max:      max,
+	}
+}
+
+func (b *BodyReader) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+
+	if b.onRead != nil {
+		b.onRead()
+		b.onRead = nil
+	}
+	return b.read0(p)
+}
+
+func (b *BodyReader) read0(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	// read one byte past the limit so an oversized body can be detected
+	if b.max > 0 && int64(len(p)) > b.max-b.read+1 {
+		p = p[:b.max-b.read+1]
+	}
+
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+
+	if b.max > 0 && b.read > b.max {
+		n -= int(b.read - b.max)
+		b.read = b.max
+		err = NewRequestError(constants.StatusRequestEntityTooLarge, "request body is too large")
+	}
+
+	if err == io.EOF && b.expected >= 0 && b.read < b.expected {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		b.err = err
+		if err == io.EOF && b.onEOF != nil {
+			b.onEOF()
+			b.onEOF = nil
+		}
+	}
+	return n, err
+}
+
+// OnEOF registers fn to run once the body has been read to its end, right
+// away when there is nothing left to read.
+func (b *BodyReader) OnEOF(fn func()) {
+	if b.err == io.EOF || b.expected == 0 {
+		fn()
+		return
+	}
+	b.onEOF = fn
+}
+
+// OnFirstRead registers fn to run right before the first Read, draining the
+// body does not trigger it.
+func (b *BodyReader) OnFirstRead(fn func()) {
+	b.onRead = fn
+}
+
+// Unread returns how many body bytes are still to be read, -1 when that is
+// unknown because the body is chunked or failed.
+func (b *BodyReader) Unread() int64 {
+	if b.err == io.EOF {
+		return 0
+	}
+	if b.err != nil || b.expected < 0 {
+		return -1
+	}
+	return b.expected - b.read
+}
+
+func (b *BodyReader) HasBody() bool {
+	return b.expected != 0
+}
+
+// SetLimit replaces the body size limit before anything has been read, it
+// fails right away when the declared Content-Length is already over it.
+func (b *BodyReader) SetLimit(max int64) error {
+	b.max = max
+	if max > 0 && b.expected > max {
+		return NewRequestError(constants.StatusRequestEntityTooLarge, "request body is too large")
+	}
+	return nil
+}
+
+func (b *BodyReader) Close() error {
+	b.closed = true
+	return nil
+}
+
+// Err returns the error that stopped the body, if it was anything but a
+// clean end of body.
+func (b *BodyReader) Err() error {
+	if b.err == io.EOF {
+		return nil
+	}
+	return b.err
+}
+
+// Drain discards up to max unread body bytes so the next request on the
+// connection can be parsed, it reports whether the body was fully consumed.
+func (b *BodyReader) Drain(max int64) bool {
+	buf := make([]byte, 4096)
+	for drained := int64(0); drained <= max; {
+		n, err := b.read0(buf)
+		drained += int64(n)
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+)
+
+const maxDrainSize = 1 << 10
+
+func TestBodyReaderLargeAndPipelined(t *testing.T) {
+	body := strings.Repeat("x", 64<<10)
+	raw := "POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body +
+		"GET /b HTTP/1.1\r\nHost: a\r\n\r\n"
+	r := bufio.NewReader(strings.NewReader(raw))
+
+	first, err := ReadRequest(r, ParseLimits{})
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	data, err := io.ReadAll(first.BodyStream)
+	if err != nil || len(data) != len(body) {
+		t.Fatalf("first body: %d bytes, %v", len(data), err)
+	}
+
+	second, err := ReadRequest(r, ParseLimits{})
+	if err != nil {
+		t.Fatalf("second request: %v", err)
+	}
+	if second.RawPath != "/b" || second.BodyStream.HasBody() {
+		t.Errorf("second request %s, has body %v", second.RawPath, second.BodyStream.HasBody())
+	}
+}
+
+func TestBodyReaderContentLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		sent   string
+		want   string
+		err    error
+	}{
+		{"exact", 5, "hello", "hello", nil},
+		{"stops at length", 5, "hello world", "hello", nil},
+		{"short body", 5, "hel", "hel", io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: " + strconv.Itoa(tt.length) + "\r\n\r\n" + tt.sent
+			info, err := readRequestString(raw, ParseLimits{})
+			if err != nil {
+				t.Fatalf("ReadRequest: %v", err)
+			}
+
+			data, err := io.ReadAll(info.BodyStream)
+			if err != tt.err || string(data) != tt.want {
+				t.Errorf("body %q, %v, want %q, %v", data, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestBodyReaderMaxBodyBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		sent   string
+		max    int64
+		status constants.StatusCode
+	}{
+		{"under limit", 4, "hell", 5, 0},
+		{"at limit", 5, "hello", 5, 0},
+		{"over limit", 6, "hello!", 5, constants.StatusRequestEntityTooLarge},
+		{"no limit", 6, "hello!", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: " + strconv.Itoa(tt.length) + "\r\n\r\n" + tt.sent
+			info, err := readRequestString(raw, ParseLimits{MaxBodyBytes: tt.max})
+			if err != nil {
+				t.Fatalf("ReadRequest: %v", err)
+			}
+
+			data, err := io.ReadAll(info.BodyStream)
+			if got := statusOf(err); got != tt.status || tt.status == 0 && string(data) != tt.sent {
+				t.Errorf("body %q, status %d (%v), want status %d", data, got, err, tt.status)
+			}
+		})
+	}
+}
+
+func TestBodyReaderSetLimit(t *testing.T) {
+	info, err := readRequestString("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 10\r\n\r\n0123456789", ParseLimits{})
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+
+	if err := info.BodyStream.SetLimit(10); err != nil {
+		t.Errorf("limit equal to Content-Length: %v", err)
+	}
+	if got := statusOf(info.BodyStream.SetLimit(9)); got != constants.StatusRequestEntityTooLarge {
+		t.Errorf("limit under Content-Length: status %d", got)
+	}
+	for _, lifted := range []int64{0, -1} {
+		if err := info.BodyStream.SetLimit(lifted); err != nil {
+			t.Errorf("SetLimit(%d): %v", lifted, err)
+		}
+	}
+}
+
+func TestBodyReaderDrain(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  int
+		read    int
+		drained bool
+	}{
+		{"unread body", 5, 0, true},
+		{"partly read body", 5, 2, true},
+		{"body at the drain limit", maxDrainSize, 0, true},
+		{"body over the drain limit", 2 * maxDrainSize, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: " + strconv.Itoa(tt.length) + "\r\n\r\n" + strings.Repeat("x", tt.length)
+			info, err := readRequestString(raw, ParseLimits{})
+			if err != nil {
+				t.Fatalf("ReadRequest: %v", err)
+			}
+
+			if tt.read > 0 {
+				info.BodyStream.Read(make([]byte, tt.read))
+			}
+			if got := info.BodyStream.Drain(maxDrainSize); got != tt.drained {
+				t.Errorf("Drain = %v, want %v", got, tt.drained)
+			}
+			if err := info.BodyStream.Err(); err != nil {
+				t.Errorf("Err after Drain: %v", err)
+			}
+		})
+	}
+}
+
+func TestBodyReaderClose(t *testing.T) {
+	info, err := readRequestString("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", ParseLimits{})
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+
+	info.BodyStream.Close()
+	if _, err := info.BodyStream.Read(make([]byte, 5)); err != ErrBodyReadAfterClose {
+		t.Errorf("read after Close: %v, want ErrBodyReadAfterClose", err)
+	}
+}
+
+func TestBodyReaderOnEOF(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		// read is how many bytes to read, -1 reads to the end twice
+		read  int
+		calls int
+	}{
+		{"no body", "GET / HTTP/1.1\r\nHost: a\r\n\r\n", 0, 1},
+		{"body not read", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", 0, 0},
+		{"body partly read", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", 2, 0},
+		{"body read to the end", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", -1, 1},
+		{"chunked body read to the end", "POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n", -1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := readRequestString(tt.raw, ParseLimits{})
+			if err != nil {
+				t.Fatalf("ReadRequest: %v", err)
+			}
+
+			calls := 0
+			info.BodyStream.OnEOF(func() { calls++ })
+			if tt.read < 0 {
+				io.ReadAll(info.BodyStream)
+				io.ReadAll(info.BodyStream)
+			} else if tt.read > 0 {
+				info.BodyStream.Read(make([]byte, tt.read))
+			}
+
+			if calls != tt.calls {
+				t.Errorf("OnEOF ran %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestBodyReaderOnFirstRead(t *testing.T) {
+	info, err := readRequestString("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", ParseLimits{})
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+	body := info.BodyStream
+
+	calls := 0
+	body.OnFirstRead(func() { calls++ })
+	if body.Drain(maxDrainSize); calls != 0 {
+		t.Errorf("Drain ran the first read hook")
+	}
+
+	body.Read(make([]byte, 2))
+	body.Read(make([]byte, 2))
+	if calls != 1 {
+		t.Errorf("first read hook ran %d times, want 1", calls)
+	}
+}
+
+func TestBodyReaderUnread(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		read    int
+		hasBody bool
+		unread  int64
+	}{
+		{"no body", "GET / HTTP/1.1\r\nHost: a\r\n\r\n", 0, false, 0},
+		{"body not read", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", 0, true, 5},
+		{"body partly read", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", 2, true, 3},
+		{"body read to the end", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello", 10, true, 0},
+		{"chunked body", "POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n", 0, true, -1},
+		{"short body", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhel", 10, true, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := readRequestString(tt.raw, ParseLimits{})
+			if err != nil {
+				t.Fatalf("ReadRequest: %v", err)
+			}
+
+			// reading past the body ends on its EOF
+			buf := make([]byte, tt.read)
+			for n := 0; n < tt.read; {
+				m, err := info.BodyStream.Read(buf[n:])
+				n += m
+				if err != nil {
+					break
+				}
+			}
+
+			if got := info.BodyStream.HasBody(); got != tt.hasBody {
+				t.Errorf("HasBody = %v, want %v", got, tt.hasBody)
+			}
+			if got := info.BodyStream.Unread(); got != tt.unread {
+				t.Errorf("Unread = %d, want %d", got, tt.unread)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+)
+
+const maxChunkLineBytes = 4096
+
+// chunkedReader decodes a Transfer-Encoding: chunked body, chunk extensions
+// are ignored and trailer fields are collected once the last chunk is read.
+type chunkedReader struct {
+	r               *bufio.Reader
+	remaining       int64
+	trailer         Header
+	maxTrailerBytes int
+	err             error
+}
+
+func newChunkedReader(r *bufio.Reader, trailer Header, maxTrailerBytes int) *chunkedReader {
+	return &chunkedReader{
+		r:               r,
+		trailer:         trailer,
+		maxTrailerBytes: maxTrailerBytes,
+	}
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+
+	if cr.remaining == 0 {
+		size, err := cr.readChunkSize()
+		if err != nil {
+			cr.err = err
+			return 0, err
+		}
+
+		if size == 0 {
+			cr.err = cr.readTrailer()
+			if cr.err == nil {
+				cr.err = io.EOF
+			}
+			return 0, cr.err
+		}
+		cr.remaining = size
+	}
+
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+
+	n, err := cr.r.Read(p)
+	cr.remaining -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		cr.err = err
+		return n, err
+	}
+
+	if cr.remaining == 0 {
+		line, _, err := readLine(cr.r, maxChunkLineBytes)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil && len(line) != 0 {
+			err = NewRequestError(constants.StatusBadRequest, "malformed chunked encoding")
+		}
+		if err != nil {
+			cr.err = err
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (cr *chunkedReader) readChunkSize() (int64, error) {
+	line, _, err := readLine(cr.r, maxChunkLineBytes)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+
+	sizeField := string(line)
+	if i := strings.IndexByte(sizeField, ';'); i >= 0 {
+		sizeField = sizeField[:i]
+	}
+	sizeField = strings.TrimRight(sizeField, " \t")
+
+	size, err := strconv.ParseUint(sizeField, 16, 63)
+	if err != nil {
+		return 0, NewRequestError(constants.StatusBadRequest, "invalid chunk size")
+	}
+
+	return int64(size), nil
+}
+
+func (cr *chunkedReader) readTrailer() error {
+	trailerBytes := 0
+	for {
+		line, n, err := readLine(cr.r, cr.maxTrailerBytes-trailerBytes)
+		trailerBytes += n
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		if len(line) == 0 {
+			return nil
+		}
+
+		name, value, err := parseHeaderLine(string(line))
+		if err != nil {
+			return err
+		}
+		cr.trailer.Add(name, value)
+	}
+}
@@ -0,0 +1,98 @@
+package util
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+)
+
+func readChunkedBody(body string, limits ParseLimits) (*ParsedRequestInfo, []byte, error) {
+	info, err := readRequestString("POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n"+body, limits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := io.ReadAll(info.BodyStream)
+	return info, data, err
+}
+
+func TestChunkedBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		trailer map[string]string
+	}{
+		{"single chunk", "5\r\nhello\r\n0\r\n\r\n", "hello", nil},
+		{"several chunks", "3\r\nhel\r\n2\r\nlo\r\n0\r\n\r\n", "hello", nil},
+		{"upper case hex", "A\r\n0123456789\r\n0\r\n\r\n", "0123456789", nil},
+		{"leading zeros", "005\r\nhello\r\n000\r\n\r\n", "hello", nil},
+		{"extensions", "5;name=value;flag\r\nhello\r\n0;last\r\n\r\n", "hello", nil},
+		{"whitespace before extension", "5 ;name=value\r\nhello\r\n0\r\n\r\n", "hello", nil},
+		{"empty body", "0\r\n\r\n", "", nil},
+		{"trailers", "5\r\nhello\r\n0\r\nChecksum: abc\r\nx-other: 1\r\n\r\n", "hello", map[string]string{"Checksum": "abc", "X-Other": "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, data, err := readChunkedBody(tt.body, ParseLimits{})
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("body %q, want %q", data, tt.want)
+			}
+			for name, value := range tt.trailer {
+				if got := info.Trailer.Get(name); got != value {
+					t.Errorf("trailer %s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkedBodyRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		limits ParseLimits
+		status constants.StatusCode
+	}{
+		{"missing size", "\r\nhello\r\n0\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"non hex size", "g\r\nhello\r\n0\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"0x prefix", "0x5\r\nhello\r\n0\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"signed size", "+5\r\nhello\r\n0\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"leading space in size", " 5\r\nhello\r\n0\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"size overflow", "ffffffffffffffffff\r\nhello\r\n0\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"data longer than size", "3\r\nhello\r\n0\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"size line too long", "5;" + strings.Repeat("x", maxChunkLineBytes) + "\r\nhello\r\n0\r\n\r\n", ParseLimits{}, constants.StatusRequestHeaderFieldsTooLarge},
+		{"obs-fold in trailer", "0\r\nX-A: b\r\n c\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"bad trailer name", "0\r\nX A: b\r\n\r\n", ParseLimits{}, constants.StatusBadRequest},
+		{"trailer over header bytes", "0\r\nX-A: " + strings.Repeat("b", 64) + "\r\n\r\n", ParseLimits{MaxHeaderBytes: 64}, constants.StatusRequestHeaderFieldsTooLarge},
+		{"body at limit", "3\r\nhel\r\n2\r\nlo\r\n0\r\n\r\n", ParseLimits{MaxBodyBytes: 5}, 0},
+		{"body over limit", "3\r\nhel\r\n3\r\nlo!\r\n0\r\n\r\n", ParseLimits{MaxBodyBytes: 5}, constants.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := tt.limits
+			if limits.MaxHeaderBytes == 0 {
+				limits.MaxHeaderBytes = DefaultMaxHeaderBytes
+			}
+			_, _, err := readChunkedBody(tt.body, limits)
+			if got := statusOf(err); got != tt.status || tt.status == 0 && err != nil {
+				t.Errorf("status %d (%v), want %d", got, err, tt.status)
+			}
+		})
+	}
+}
+
+func TestChunkedBodyTruncated(t *testing.T) {
+	for _, body := range []string{"5\r\nhel", "5\r\nhello", "5\r\nhello\r\n", "5\r\nhello\r\n0\r\n", "5\r\nhello\r\n0\r\nX-A: b\r\n"} {
+		if _, _, err := readChunkedBody(body, ParseLimits{}); err != io.ErrUnexpectedEOF {
+			t.Errorf("%q: %v, want ErrUnexpectedEOF", body, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"sort"
+	"strings"
+)
+
+// Header maps canonical header names to every value received or set for
+// them, in order.
+type Header map[string][]string
+
+func (h Header) Get(key string) string {
+	values := h[CanonicalHeaderKey(key)]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (h Header) Values(key string) []string {
+	return h[CanonicalHeaderKey(key)]
+}
+
+func (h Header) Has(key string) bool {
+	_, exists := h[CanonicalHeaderKey(key)]
+	return exists
+}
+
+func (h Header) Add(key, value string) {
+	key = CanonicalHeaderKey(key)
+	h[key] = append(h[key], value)
+}
+
+func (h Header) Set(key, value string) {
+	h[CanonicalHeaderKey(key)] = []string{value}
+}
+
+func (h Header) Del(key string) {
+	delete(h, CanonicalHeaderKey(key))
+}
+
+func (h Header) Clone() Header {
+	clone := make(Header, len(h))
+	for k, v := range h {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// Keys returns the header names in sorted order so headers are written out
+// deterministically.
+func (h Header) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CanonicalHeaderKey upper-cases the first letter and every letter following
+// a hyphen and lower-cases the rest, so "content-length" becomes
+// "Content-Length". Keys with characters outside a token are left alone.
+func CanonicalHeaderKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		if !isTokenChar(key[i]) {
+			return key
+		}
+	}
+
+	upper := true
+	return strings.Map(func(r rune) rune {
+		if upper && 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		} else if !upper && 'A' <= r && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+		upper = r == '-'
+		return r
+	}, key)
+}
+
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
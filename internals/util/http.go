@@ -1 +1,21 @@
 package util
+
+import "github.com/codecrafters-io/http-server-starter-go/internals/constants"
+
+// RequestError is returned by the parser when a request is malformed or
+// breaks a limit, and carries the status the server should answer with.
+type RequestError struct {
+	StatusCode constants.StatusCode
+	Reason     string
+}
+
+func NewRequestError(status constants.StatusCode, reason string) *RequestError {
+	return &RequestError{
+		StatusCode: status,
+		Reason:     reason,
+	}
+}
+
+func (e *RequestError) Error() string {
+	return e.Reason
+}
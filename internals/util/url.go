@@ -4,7 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
-	"log"
+	"io"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -12,95 +13,346 @@ import (
 )
 
 type ParsedRequestInfo struct {
-	Method        string
+	Method string
+	// RequestURI is the request-target exactly as sent, RawPath, RawQuery
+	// and Fragment are its undecoded parts and Path is RawPath
+	// percent-decoded.
+	RequestURI    string
 	Path          string
+	RawPath       string
+	RawQuery      string
+	Fragment      string
+	Proto         string
+	ProtoMajor    int
+	ProtoMinor    int
 	Host          string
 	UserAgent     string
 	ContentType   string
 	ContentLength int
-	Header        map[string]string
-	Body          []byte
+	// TransferEncoding holds the raw Transfer-Encoding value, BodyStream
+	// decodes the body when it is "chunked".
+	TransferEncoding string
+	Header           Header
+	// Trailer is filled once a chunked body has been read to the end.
+	Trailer Header
+	// Body is only filled once the body has been buffered, BodyStream reads
+	// it straight from the connection instead.
+	Body       []byte
+	BodyStream *BodyReader
 }
 
-func ExtractUrl(buff []byte) (*ParsedRequestInfo, error) {
-	parts := bytes.SplitN(buff, []byte("\r\n\r\n"), 2)
-	if len(parts) < 2 {
-		return nil, errors.New("invalid HTTP request format")
+// ParseLimits bounds what ReadRequest accepts, zero values fall back to the
+// defaults below except for MaxBodyBytes where zero means no limit.
+type ParseLimits struct {
+	MaxHeaderBytes int
+	MaxHeaderCount int
+	MaxURILength   int
+	MaxBodyBytes   int64
+}
+
+const (
+	DefaultMaxHeaderBytes = 1 << 20
+	DefaultMaxHeaderCount = 100
+	DefaultMaxURILength   = 8 << 10
+)
+
+// readLine reads a single CRLF (or bare LF) terminated line without the line
+// terminator, failing once the line grows past max bytes.
+func readLine(r *bufio.Reader, max int) (line []byte, n int, err error) {
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > max {
+			return nil, len(line), NewRequestError(constants.StatusRequestHeaderFieldsTooLarge, "request header is too large")
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, len(line), err
+		}
+		break
 	}
 
-	headersPart := string(parts[0])
-	bodyPart := parts[1]
+	n = len(line)
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	return line, n, nil
+}
+
+func ReadRequest(r *bufio.Reader, limits ParseLimits) (*ParsedRequestInfo, error) {
+	if limits.MaxHeaderBytes <= 0 {
+		limits.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if limits.MaxHeaderCount <= 0 {
+		limits.MaxHeaderCount = DefaultMaxHeaderCount
+	}
+	if limits.MaxURILength <= 0 {
+		limits.MaxURILength = DefaultMaxURILength
+	}
 
 	info := ParsedRequestInfo{
-		Header: make(map[string]string),
+		Header: make(Header),
 	}
 
-	scanner := bufio.NewScanner(strings.NewReader(headersPart))
-	for scanner.Scan() {
-		line := scanner.Text()
+	headerBytes := 0
+	headerCount := 0
+	for {
+		line, n, err := readLine(r, limits.MaxHeaderBytes-headerBytes)
+		headerBytes += n
 
-		if line == "" {
-			break
+		var reqErr *RequestError
+		if info.Method == "" && errors.As(err, &reqErr) && reqErr.StatusCode == constants.StatusRequestHeaderFieldsTooLarge {
+			return nil, NewRequestError(constants.StatusRequestURITooLong, "request line is too long")
+		}
+		if err != nil {
+			return nil, err
 		}
 
 		if info.Method == "" {
-			for _, m := range constants.AllHTTPMethods {
-				if strings.HasPrefix(line, m+" ") {
-					parts := strings.Split(line, " ")
-					if len(parts) >= 2 {
-						info.Method = m
-						info.Path = strings.TrimSpace(parts[1])
-					}
-					break
-				}
+			// a client may send stray CRLFs between requests, ignore them
+			if len(line) == 0 {
+				continue
+			}
+
+			if err := info.parseRequestLine(string(line)); err != nil {
+				return nil, err
 			}
-		}
 
-		lineParts := strings.SplitN(line, ":", 2)
-		if len(lineParts) < 2 {
+			if len(info.RequestURI) > limits.MaxURILength {
+				return nil, NewRequestError(constants.StatusRequestURITooLong, "request target is too long")
+			}
 			continue
 		}
 
-		header := strings.TrimSpace(lineParts[0])
-		content := strings.TrimSpace(lineParts[1])
+		if len(line) == 0 {
+			break
+		}
+
+		headerCount++
+		if headerCount > limits.MaxHeaderCount {
+			return nil, NewRequestError(constants.StatusRequestHeaderFieldsTooLarge, "too many request headers")
+		}
+
+		header, content, err := parseHeaderLine(string(line))
+		if err != nil {
+			return nil, err
+		}
+		header = CanonicalHeaderKey(header)
 
 		switch header {
 		case "Host":
-			info.Host = content
+			if info.Header.Has(header) {
+				return nil, NewRequestError(constants.StatusBadRequest, "multiple Host headers")
+			}
+			if info.Host == "" {
+				info.Host = content
+			}
 		case "User-Agent":
 			info.UserAgent = content
 		case "Content-Type":
 			info.ContentType = content
 		case "Content-Length":
 			length, err := strconv.Atoi(content)
-			if err != nil {
-				log.Printf("invalid Content-Length: %v", err)
-			} else {
-				info.ContentLength = length
+			if err != nil || !isDigits(content) {
+				return nil, NewRequestError(constants.StatusBadRequest, "invalid Content-Length")
+			}
+			// repeating the same length is allowed, disagreeing ones are not
+			if info.Header.Has(header) && length != info.ContentLength {
+				return nil, NewRequestError(constants.StatusBadRequest, "conflicting Content-Length")
+			}
+			info.ContentLength = length
+		case "Transfer-Encoding":
+			if info.TransferEncoding != "" {
+				content = info.TransferEncoding + ", " + content
 			}
-			info.Body = bodyPart[:length]
+			info.TransferEncoding = content
 		}
 
-		info.Header[header] = content
+		info.Header.Add(header, content)
 	}
 
-	if info.ContentLength > 0 {
-		if len(bodyPart) >= info.ContentLength {
-			info.Body = bodyPart[:info.ContentLength]
-		} else {
-			return nil, errors.New("body is shorter than declared Content-Length")
+	// Host only became mandatory with HTTP/1.1
+	if info.Host == "" && !info.Header.Has("Host") && info.ProtoAtLeast(1, 1) {
+		return nil, NewRequestError(constants.StatusBadRequest, "could not find host line")
+	}
+
+	hasContentLength := info.Header.Has("Content-Length")
+
+	if info.TransferEncoding != "" {
+		// a message carrying both framings is a request smuggling vector
+		if hasContentLength {
+			return nil, NewRequestError(constants.StatusBadRequest, "both Content-Length and Transfer-Encoding are set")
 		}
-	} else {
-		info.Body = bodyPart
+
+		if !strings.EqualFold(info.TransferEncoding, "chunked") {
+			return nil, NewRequestError(constants.StatusNotImplemented, "unsupported Transfer-Encoding")
+		}
+
+		info.Trailer = make(Header)
+		info.BodyStream = newBodyReader(newChunkedReader(r, info.Trailer, limits.MaxHeaderBytes), -1, limits.MaxBodyBytes)
+		return &info, nil
 	}
 
-	if info.Path == "" {
-		return nil, errors.New("could not find path line")
+	info.BodyStream = newBodyReader(r, int64(info.ContentLength), limits.MaxBodyBytes)
+	return &info, nil
+}
+
+func (info *ParsedRequestInfo) ProtoAtLeast(major, minor int) bool {
+	return info.ProtoMajor > major || info.ProtoMajor == major && info.ProtoMinor >= minor
+}
+
+// parseRequestLine validates "method SP request-target SP HTTP-version" as
+// RFC 9112 lays it out, single spaces and nothing else in between.
+func (info *ParsedRequestInfo) parseRequestLine(line string) error {
+	parts := strings.Split(line, " ")
+	if len(parts) != 3 {
+		return NewRequestError(constants.StatusBadRequest, "malformed request line")
 	}
 
-	if info.Host == "" {
-		return nil, errors.New("could not find host line")
+	method, target, proto := parts[0], parts[1], parts[2]
+
+	if !isToken(method) {
+		return NewRequestError(constants.StatusBadRequest, "invalid method")
 	}
 
-	return &info, nil
+	if !isValidProto(proto) {
+		return NewRequestError(constants.StatusBadRequest, "malformed HTTP version")
+	}
+
+	if proto[5] != '1' {
+		return NewRequestError(constants.StatusHTTPVersionNotSupported, "unsupported HTTP version")
+	}
+
+	if target == "" {
+		return NewRequestError(constants.StatusBadRequest, "missing request target")
+	}
+
+	for i := 0; i < len(target); i++ {
+		if target[i] <= ' ' || target[i] >= 0x7f {
+			return NewRequestError(constants.StatusBadRequest, "invalid character in request target")
+		}
+	}
+
+	info.Method = method
+	info.RequestURI = target
+	info.Proto = proto
+	info.ProtoMajor = int(proto[5] - '0')
+	info.ProtoMinor = int(proto[7] - '0')
+
+	return info.splitTarget()
+}
+
+// splitTarget breaks the request-target into its path, query and fragment.
+// The path is only decoded as a whole for Path, routing works on RawPath so
+// an encoded slash never turns into a segment boundary.
+func (info *ParsedRequestInfo) splitTarget() error {
+	target := info.RequestURI
+
+	switch {
+	case target == "*":
+		if info.Method != constants.OPTIONS {
+			return NewRequestError(constants.StatusBadRequest, "asterisk-form is only allowed for OPTIONS")
+		}
+		info.RawPath = target
+		info.Path = target
+		return nil
+
+	case strings.HasPrefix(target, "/"):
+
+	case hasHTTPScheme(target):
+		// absolute-form, the authority replaces the Host header
+		rest := target[strings.Index(target, "://")+3:]
+		end := strings.IndexAny(rest, "/?#")
+		if end < 0 {
+			end = len(rest)
+		}
+
+		info.Host = rest[:end]
+		if info.Host == "" {
+			return NewRequestError(constants.StatusBadRequest, "missing authority in request target")
+		}
+
+		target = rest[end:]
+		if !strings.HasPrefix(target, "/") {
+			target = "/" + target
+		}
+
+	default:
+		return NewRequestError(constants.StatusBadRequest, "unsupported request target form")
+	}
+
+	if i := strings.IndexByte(target, '#'); i >= 0 {
+		target, info.Fragment = target[:i], target[i+1:]
+	}
+
+	if i := strings.IndexByte(target, '?'); i >= 0 {
+		target, info.RawQuery = target[:i], target[i+1:]
+	}
+
+	path, err := url.PathUnescape(target)
+	if err != nil {
+		return NewRequestError(constants.StatusBadRequest, "invalid escape in request path")
+	}
+
+	info.RawPath = target
+	info.Path = path
+	return nil
+}
+
+func hasHTTPScheme(target string) bool {
+	lower := strings.ToLower(target)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func isValidProto(proto string) bool {
+	return len(proto) == 8 &&
+		strings.HasPrefix(proto, "HTTP/") &&
+		isDigits(proto[5:6]) &&
+		proto[6] == '.' &&
+		isDigits(proto[7:8])
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHeaderLine splits "name: value" and rejects what RFC 9112 forbids:
+// obsolete line folding, whitespace before the colon, names that are not
+// tokens and control characters in the value.
+func parseHeaderLine(line string) (name string, value string, err error) {
+	if line[0] == ' ' || line[0] == '\t' {
+		return "", "", NewRequestError(constants.StatusBadRequest, "obsolete line folding")
+	}
+
+	colon := strings.IndexByte(line, ':')
+	if colon < 0 {
+		return "", "", NewRequestError(constants.StatusBadRequest, "malformed header line")
+	}
+
+	name = line[:colon]
+	if !isToken(name) {
+		return "", "", NewRequestError(constants.StatusBadRequest, "invalid header name")
+	}
+
+	value = strings.Trim(line[colon+1:], " \t")
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == 0x7f || (c < ' ' && c != '\t') {
+			return "", "", NewRequestError(constants.StatusBadRequest, "invalid header value")
+		}
+	}
+
+	return name, value, nil
 }
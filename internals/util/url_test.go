@@ -0,0 +1,176 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/internals/constants"
+)
+
+func readRequestString(raw string, limits ParseLimits) (*ParsedRequestInfo, error) {
+	return ReadRequest(bufio.NewReader(strings.NewReader(raw)), limits)
+}
+
+// statusOf returns the status a RequestError carries, zero for any other
+// error.
+func statusOf(err error) constants.StatusCode {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.StatusCode
+	}
+	return 0
+}
+
+func TestReadRequest(t *testing.T) {
+	raw := "\r\nPOST http://example.com/a%2Fb/c?x=1&y=2#top HTTP/1.1\r\n" +
+		"Host: ignored.example\r\n" +
+		"user-agent: test/1.0\r\n" +
+		"X-Multi: one\r\n" +
+		"X-Multi: two\r\n" +
+		"Content-Length: 5\r\n" +
+		"Content-Length: 5\r\n" +
+		"\r\n" +
+		"hello"
+
+	info, err := readRequestString(raw, ParseLimits{})
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+
+	checks := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"Method", info.Method, "POST"},
+		{"RawPath", info.RawPath, "/a%2Fb/c"},
+		{"Path", info.Path, "/a/b/c"},
+		{"RawQuery", info.RawQuery, "x=1&y=2"},
+		{"Fragment", info.Fragment, "top"},
+		{"Host", info.Host, "example.com"},
+		{"UserAgent", info.UserAgent, "test/1.0"},
+		{"ProtoMinor", info.ProtoMinor, 1},
+		{"ContentLength", info.ContentLength, 5},
+		{"X-Multi", strings.Join(info.Header.Values("X-Multi"), ","), "one,two"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	body, err := io.ReadAll(info.BodyStream)
+	if err != nil || string(body) != "hello" {
+		t.Errorf("body = %q, %v", body, err)
+	}
+}
+
+func TestReadRequestRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		status constants.StatusCode
+	}{
+		{"two spaces in request line", "GET  / HTTP/1.1\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"missing version", "GET /\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"method not a token", "G(T / HTTP/1.1\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"lowercase version", "GET / http/1.1\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"two digit version", "GET / HTTP/1.10\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"HTTP/2", "GET / HTTP/2.0\r\nHost: a\r\n\r\n", constants.StatusHTTPVersionNotSupported},
+		{"HTTP/0.9", "GET / HTTP/0.9\r\nHost: a\r\n\r\n", constants.StatusHTTPVersionNotSupported},
+		{"control character in target", "GET /a\x01b HTTP/1.1\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"relative target", "GET a/b HTTP/1.1\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"asterisk for GET", "GET * HTTP/1.1\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"bad escape", "GET /%zz HTTP/1.1\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"absolute form without authority", "GET http:///a HTTP/1.1\r\nHost: a\r\n\r\n", constants.StatusBadRequest},
+		{"missing host", "GET / HTTP/1.1\r\n\r\n", constants.StatusBadRequest},
+		{"duplicate host", "GET / HTTP/1.1\r\nHost: a\r\nHost: b\r\n\r\n", constants.StatusBadRequest},
+		{"obs-fold", "GET / HTTP/1.1\r\nHost: a\r\nX-A: b\r\n c\r\n\r\n", constants.StatusBadRequest},
+		{"space before colon", "GET / HTTP/1.1\r\nHost: a\r\nX-A : b\r\n\r\n", constants.StatusBadRequest},
+		{"no colon", "GET / HTTP/1.1\r\nHost: a\r\nX-A b\r\n\r\n", constants.StatusBadRequest},
+		{"empty header name", "GET / HTTP/1.1\r\nHost: a\r\n: b\r\n\r\n", constants.StatusBadRequest},
+		{"control character in value", "GET / HTTP/1.1\r\nHost: a\r\nX-A: b\x00c\r\n\r\n", constants.StatusBadRequest},
+		{"negative content length", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: -1\r\n\r\n", constants.StatusBadRequest},
+		{"signed content length", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: +5\r\n\r\n", constants.StatusBadRequest},
+		{"content length list", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5, 5\r\n\r\n", constants.StatusBadRequest},
+		{"content length overflow", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 99999999999999999999\r\n\r\n", constants.StatusBadRequest},
+		{"conflicting content lengths", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\nContent-Length: 6\r\n\r\n", constants.StatusBadRequest},
+		{"content length and chunked", "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\nTransfer-Encoding: chunked\r\n\r\n", constants.StatusBadRequest},
+		{"chunked and content length", "POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n", constants.StatusBadRequest},
+		{"gzip encoding", "POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: gzip\r\n\r\n", constants.StatusNotImplemented},
+		{"chunked twice", "POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n", constants.StatusNotImplemented},
+		{"gzip then chunked", "POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: gzip, chunked\r\n\r\n", constants.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readRequestString(tt.raw, ParseLimits{})
+			if got := statusOf(err); got != tt.status {
+				t.Errorf("status %d (%v), want %d", got, err, tt.status)
+			}
+		})
+	}
+}
+
+func TestReadRequestAccepts(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"HTTP/1.0 without host", "GET / HTTP/1.0\r\n\r\n"},
+		{"bare LF line endings", "GET / HTTP/1.1\nHost: a\n\n"},
+		{"asterisk for OPTIONS", "OPTIONS * HTTP/1.1\r\nHost: a\r\n\r\n"},
+		{"extension method", "PROPFIND / HTTP/1.1\r\nHost: a\r\n\r\n"},
+		{"chunked in any case", "POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: Chunked\r\n\r\n0\r\n\r\n"},
+		{"tab in value", "GET / HTTP/1.1\r\nHost: a\r\nX-A: b\tc\r\n\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readRequestString(tt.raw, ParseLimits{}); err != nil {
+				t.Errorf("ReadRequest: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadRequestEOF(t *testing.T) {
+	if _, err := readRequestString("", ParseLimits{}); err != io.EOF {
+		t.Errorf("empty input: %v, want EOF", err)
+	}
+
+	if _, err := readRequestString("GET / HTTP/1.1\r\nHost: a\r\n", ParseLimits{}); err != io.EOF && err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated headers: %v, want an EOF", err)
+	}
+}
+
+func TestReadRequestLimits(t *testing.T) {
+	const head = "GET /abcdefgh HTTP/1.1\r\nHost: a\r\nX-A: 1\r\nX-B: 2\r\n\r\n"
+
+	tests := []struct {
+		name   string
+		raw    string
+		limits ParseLimits
+		status constants.StatusCode
+	}{
+		{"uri at limit", head, ParseLimits{MaxURILength: len("/abcdefgh")}, 0},
+		{"uri over limit", head, ParseLimits{MaxURILength: len("/abcdefgh") - 1}, constants.StatusRequestURITooLong},
+		{"request line over header bytes", head, ParseLimits{MaxHeaderBytes: 10}, constants.StatusRequestURITooLong},
+		{"header count at limit", head, ParseLimits{MaxHeaderCount: 3}, 0},
+		{"header count over limit", head, ParseLimits{MaxHeaderCount: 2}, constants.StatusRequestHeaderFieldsTooLarge},
+		{"header bytes at limit", head, ParseLimits{MaxHeaderBytes: len(head)}, 0},
+		{"header bytes over limit", head, ParseLimits{MaxHeaderBytes: len(head) - 1}, constants.StatusRequestHeaderFieldsTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readRequestString(tt.raw, tt.limits)
+			if got := statusOf(err); got != tt.status || tt.status == 0 && err != nil {
+				t.Errorf("status %d (%v), want %d", got, err, tt.status)
+			}
+		})
+	}
+}